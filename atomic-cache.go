@@ -0,0 +1,354 @@
+package sense
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type atomicCache interface {
+	run(script atomicScript, keys []string, args ...int64) ([]int64, error)
+	add(key, member string, ttl time.Duration) error
+	remove(key string, members ...string) error
+	members(key string) ([]string, error)
+	load(key string) (string, bool, error)
+	store(key, value string, ttl time.Duration) error
+}
+
+type atomicScript struct {
+	lua    *redis.Script
+	memory func(values atomicValues, keys []string, args []int64) []int64
+}
+
+type atomicValues interface {
+	get(key string) (int64, bool)
+	set(key string, value int64, ttl time.Duration)
+	delete(key string)
+}
+
+type redisAtomicCache struct {
+	ctx    context.Context
+	client *redis.Client
+}
+
+type memoryAtomicCache struct {
+	shards []*memoryAtomicShard
+}
+
+type memoryAtomicShard struct {
+	mu     sync.Mutex
+	values map[string]memoryAtomicValue
+	writes int
+}
+
+type memoryAtomicValue struct {
+	value   int64
+	data    string
+	members map[string]bool
+	expires time.Time
+}
+
+type memoryAtomicView struct {
+	cache *memoryAtomicCache
+	now   time.Time
+}
+
+const (
+	memoryAtomicShards = 64
+	memoryAtomicSweep  = 1024
+)
+
+var (
+	atomicIncrementScript = atomicScript{
+		lua: redis.NewScript(
+			`local count = redis.call('INCR', KEYS[1])
+if count == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return {count}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			count, _ := values.get(keys[0])
+			count++
+			if count == 1 {
+				values.set(keys[0], count, time.Duration(args[0])*time.Millisecond)
+			}
+			if count > 1 {
+				values.set(keys[0], count, -1)
+			}
+			return []int64{count}
+		},
+	}
+	atomicGetScript = atomicScript{
+		lua: redis.NewScript(
+			`local value = redis.call('GET', KEYS[1])
+if not value then return {0, 0} end
+return {1, tonumber(value)}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			value, ok := values.get(keys[0])
+			if !ok {
+				return []int64{0, 0}
+			}
+			return []int64{1, value}
+		},
+	}
+	atomicSetScript = atomicScript{
+		lua: redis.NewScript(
+			`redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return {1}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			values.set(keys[0], args[0], time.Duration(args[1])*time.Millisecond)
+			return []int64{1}
+		},
+	}
+	atomicDeleteScript = atomicScript{
+		lua: redis.NewScript(
+			`return {redis.call('DEL', unpack(KEYS))}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			for _, key := range keys {
+				values.delete(key)
+			}
+			return []int64{int64(len(keys))}
+		},
+	}
+)
+
+func createAtomicCache(cache config.Cache) atomicCache {
+	if cache.Redis != nil {
+		return redisAtomicCache{ctx: context.Background(), client: cache.Redis}
+	}
+	return createMemoryAtomicCache()
+}
+
+func createMemoryAtomicCache() *memoryAtomicCache {
+	c := &memoryAtomicCache{shards: make([]*memoryAtomicShard, memoryAtomicShards)}
+	for i := range c.shards {
+		c.shards[i] = &memoryAtomicShard{values: make(map[string]memoryAtomicValue)}
+	}
+	return c
+}
+
+func (c redisAtomicCache) run(script atomicScript, keys []string, args ...int64) ([]int64, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	result, err := script.lua.Run(c.ctx, c.client, keys, values...).Result()
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]any)
+	if !ok {
+		return nil, ErrorInvalidCache
+	}
+	numbers := make([]int64, len(items))
+	for i, item := range items {
+		number, ok := item.(int64)
+		if !ok {
+			return nil, ErrorInvalidCache
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
+
+func (c redisAtomicCache) add(key, member string, ttl time.Duration) error {
+	_, err := c.client.TxPipelined(
+		c.ctx, func(pipe redis.Pipeliner) error {
+			pipe.SAdd(c.ctx, key, member)
+			pipe.PExpire(c.ctx, key, ttl)
+			return nil
+		},
+	)
+	return err
+}
+
+func (c redisAtomicCache) remove(key string, members ...string) error {
+	values := make([]any, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+	return c.client.SRem(c.ctx, key, values...).Err()
+}
+
+func (c redisAtomicCache) members(key string) ([]string, error) {
+	return c.client.SMembers(c.ctx, key).Result()
+}
+
+func (c redisAtomicCache) load(key string) (string, bool, error) {
+	value, err := c.client.Get(c.ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (c redisAtomicCache) store(key, value string, ttl time.Duration) error {
+	return c.client.Set(c.ctx, key, value, ttl).Err()
+}
+
+func (c *memoryAtomicCache) run(script atomicScript, keys []string, args ...int64) ([]int64, error) {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		if index := c.index(key); !slices.Contains(indexes, index) {
+			indexes = append(indexes, index)
+		}
+	}
+	slices.Sort(indexes)
+	for _, index := range indexes {
+		c.shards[index].mu.Lock()
+	}
+	defer func() {
+		for _, index := range indexes {
+			c.shards[index].mu.Unlock()
+		}
+	}()
+	return script.memory(memoryAtomicView{cache: c, now: time.Now()}, keys, args), nil
+}
+
+func (c *memoryAtomicCache) add(key, member string, ttl time.Duration) error {
+	shard := c.shards[c.index(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	now := time.Now()
+	value, ok := shard.values[key]
+	if !ok || now.After(value.expires) {
+		value = memoryAtomicValue{members: make(map[string]bool)}
+	}
+	value.members[member] = true
+	value.expires = now.Add(ttl)
+	shard.values[key] = value
+	return nil
+}
+
+func (c *memoryAtomicCache) remove(key string, members ...string) error {
+	shard := c.shards[c.index(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	value, ok := shard.values[key]
+	if !ok {
+		return nil
+	}
+	for _, member := range members {
+		delete(value.members, member)
+	}
+	if len(value.members) == 0 {
+		delete(shard.values, key)
+	}
+	return nil
+}
+
+func (c *memoryAtomicCache) members(key string) ([]string, error) {
+	shard := c.shards[c.index(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	result := make([]string, 0)
+	value, ok := shard.values[key]
+	if !ok || time.Now().After(value.expires) {
+		return result, nil
+	}
+	for member := range value.members {
+		result = append(result, member)
+	}
+	return result, nil
+}
+
+func (c *memoryAtomicCache) load(key string) (string, bool, error) {
+	shard := c.shards[c.index(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	value, ok := shard.values[key]
+	if !ok || time.Now().After(value.expires) {
+		return "", false, nil
+	}
+	return value.data, true, nil
+}
+
+func (c *memoryAtomicCache) store(key, value string, ttl time.Duration) error {
+	shard := c.shards[c.index(key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	now := time.Now()
+	shard.write(key, memoryAtomicValue{data: value, expires: now.Add(ttl)}, now)
+	return nil
+}
+
+func (c *memoryAtomicCache) index(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % memoryAtomicShards)
+}
+
+func (v memoryAtomicView) get(key string) (int64, bool) {
+	shard := v.cache.shards[v.cache.index(key)]
+	value, ok := shard.values[key]
+	if !ok {
+		return 0, false
+	}
+	if v.now.After(value.expires) {
+		delete(shard.values, key)
+		return 0, false
+	}
+	return value.value, true
+}
+
+func (v memoryAtomicView) set(key string, value int64, ttl time.Duration) {
+	shard := v.cache.shards[v.cache.index(key)]
+	expires := v.now.Add(ttl)
+	if current, ok := shard.values[key]; ok && ttl < 0 {
+		expires = current.expires
+	}
+	shard.write(key, memoryAtomicValue{value: value, expires: expires}, v.now)
+}
+
+func (s *memoryAtomicShard) write(key string, value memoryAtomicValue, now time.Time) {
+	s.values[key] = value
+	s.writes++
+	if s.writes < memoryAtomicSweep {
+		return
+	}
+	s.writes = 0
+	for k, item := range s.values {
+		if now.After(item.expires) {
+			delete(s.values, k)
+		}
+	}
+}
+
+func (v memoryAtomicView) delete(key string) {
+	delete(v.cache.shards[v.cache.index(key)].values, key)
+}
+
+func incrementAtomic(cache atomicCache, key string, ttl time.Duration) (int, error) {
+	result, err := cache.run(atomicIncrementScript, []string{key}, ttl.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+func getAtomic(cache atomicCache, key string) (int64, bool, error) {
+	result, err := cache.run(atomicGetScript, []string{key})
+	if err != nil {
+		return 0, false, err
+	}
+	return result[1], result[0] == 1, nil
+}
+
+func setAtomic(cache atomicCache, key string, value int64, ttl time.Duration) error {
+	_, err := cache.run(atomicSetScript, []string{key}, value, max(ttl.Milliseconds(), 1))
+	return err
+}
+
+func deleteAtomic(cache atomicCache, keys ...string) error {
+	_, err := cache.run(atomicDeleteScript, keys)
+	return err
+}
@@ -0,0 +1,281 @@
+package sense
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/creamsensation/quirk"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type AuditManager interface {
+	Create(entry config.AuditEntry) error
+	List(filter AuditFilter) ([]config.AuditEntry, error)
+	Count(filter AuditFilter) (int, error)
+}
+
+type AuditFilter struct {
+	Action    string
+	Target    string
+	UserId    int
+	Subject   string
+	Ip        string
+	Route     string
+	RequestId string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+type auditManager struct {
+	db *quirk.DB
+}
+
+type auditRow struct {
+	Id        int
+	Action    string
+	Target    string
+	Details   quirk.Jsonb[any]
+	UserId    sql.Null[int]
+	Subject   string
+	Ip        string
+	UserAgent string
+	Route     string
+	RequestId string
+	CreatedAt time.Time
+}
+
+const (
+	AuditAction    = "action"
+	AuditTarget    = "target"
+	AuditDetails   = "details"
+	AuditUserId    = "user_id"
+	AuditSubject   = "subject"
+	AuditIp        = "ip"
+	AuditUserAgent = "user_agent"
+	AuditRoute     = "route"
+	AuditRequestId = "request_id"
+)
+
+const (
+	auditsTable  = "audits"
+	auditColumns = "id, action, target, details, user_id, subject, ip, user_agent, route, request_id, created_at"
+)
+
+var (
+	auditMethods  = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	pgAuditFields = []quirk.Field{
+		{Name: quirk.Id, Props: "serial primary key"},
+		{Name: AuditAction, Props: "varchar(255) not null"},
+		{Name: AuditTarget, Props: "varchar(255) not null default ''"},
+		{Name: AuditDetails, Props: "jsonb not null default '{}'"},
+		{Name: AuditUserId, Props: "int"},
+		{Name: AuditSubject, Props: "varchar(255) not null default ''"},
+		{Name: AuditIp, Props: "varchar(64) not null default ''"},
+		{Name: AuditUserAgent, Props: "text not null default ''"},
+		{Name: AuditRoute, Props: "varchar(255) not null default ''"},
+		{Name: AuditRequestId, Props: "varchar(128) not null default ''"},
+		{Name: quirk.CreatedAt, Props: "timestamp not null default current_timestamp"},
+	}
+	mysqlAuditFields = []quirk.Field{
+		{Name: quirk.Id, Props: "int not null auto_increment primary key"},
+		{Name: AuditAction, Props: "varchar(255) not null"},
+		{Name: AuditTarget, Props: "varchar(255) not null default ''"},
+		{Name: AuditDetails, Props: "json not null"},
+		{Name: AuditUserId, Props: "int"},
+		{Name: AuditSubject, Props: "varchar(255) not null default ''"},
+		{Name: AuditIp, Props: "varchar(64) not null default ''"},
+		{Name: AuditUserAgent, Props: "varchar(1024) not null default ''"},
+		{Name: AuditRoute, Props: "varchar(255) not null default ''"},
+		{Name: AuditRequestId, Props: "varchar(128) not null default ''"},
+		{Name: quirk.CreatedAt, Props: "timestamp not null default current_timestamp"},
+	}
+)
+
+func createAuditManager(db *quirk.DB) AuditManager {
+	return &auditManager{db: db}
+}
+
+func (m *auditManager) Create(entry config.AuditEntry) error {
+	details := entry.Details
+	if details == nil {
+		details = make(map[string]any)
+	}
+	detailsBytes, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return quirk.New(m.db).Q(fmt.Sprintf(`INSERT INTO %s`, auditsTable)).
+		Q(
+			fmt.Sprintf(
+				`(%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)`,
+				AuditAction, AuditTarget, AuditDetails, AuditUserId, AuditSubject,
+				AuditIp, AuditUserAgent, AuditRoute, AuditRequestId, quirk.CreatedAt,
+			),
+		).
+		Q(
+			`VALUES (@action, @target, @details, @user_id, @subject, @ip, @user_agent, @route, @request_id, @created_at)`,
+			quirk.Map{
+				AuditAction:     entry.Action,
+				AuditTarget:     entry.Target,
+				AuditDetails:    string(detailsBytes),
+				AuditUserId:     sql.Null[int]{V: entry.UserId, Valid: entry.UserId > 0},
+				AuditSubject:    entry.Subject,
+				AuditIp:         entry.Ip,
+				AuditUserAgent:  entry.UserAgent,
+				AuditRoute:      entry.Route,
+				AuditRequestId:  entry.RequestId,
+				quirk.CreatedAt: entry.CreatedAt,
+			},
+		).
+		Exec()
+}
+
+func (m *auditManager) List(filter AuditFilter) ([]config.AuditEntry, error) {
+	rows := make([]auditRow, 0)
+	q := quirk.New(m.db).Q(fmt.Sprintf(`SELECT %s FROM %s`, auditColumns, auditsTable))
+	err := applyAuditFilter(q, filter).
+		Q(`ORDER BY id DESC`).
+		If(filter.Limit > 0, `LIMIT @limit`, quirk.Map{"limit": filter.Limit}).
+		If(filter.Offset > 0, `OFFSET @offset`, quirk.Map{"offset": filter.Offset}).
+		Exec(&rows)
+	result := make([]config.AuditEntry, len(rows))
+	for i, row := range rows {
+		result[i] = config.AuditEntry{
+			Id:        row.Id,
+			Action:    row.Action,
+			Target:    row.Target,
+			Details:   row.Details,
+			UserId:    row.UserId.V,
+			Subject:   row.Subject,
+			Ip:        row.Ip,
+			UserAgent: row.UserAgent,
+			Route:     row.Route,
+			RequestId: row.RequestId,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return result, err
+}
+
+func (m *auditManager) Count(filter AuditFilter) (int, error) {
+	var count int
+	q := quirk.New(m.db).Q(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, auditsTable))
+	err := applyAuditFilter(q, filter).Exec(&count)
+	return count, err
+}
+
+func CreateAuditsTable(q *quirk.Quirk) error {
+	fields := make([]quirk.Field, 0)
+	switch q.DB.DriverName() {
+	case quirk.Postgres:
+		fields = append(fields, pgAuditFields...)
+	case quirk.Mysql:
+		fields = append(fields, mysqlAuditFields...)
+	default:
+		return ErrorInvalidDriver
+	}
+	q.Q(
+		fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (%s)`,
+			auditsTable,
+			quirk.CreateTableStructure(fields),
+		),
+	)
+	return q.Exec()
+}
+
+func DropAuditsTable(q *quirk.Quirk) error {
+	return q.Q(fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, auditsTable)).Exec()
+}
+
+func applyAuditFilter(q *quirk.Quirk, filter AuditFilter) *quirk.Quirk {
+	return q.Q(`WHERE true`).
+		If(len(filter.Action) > 0, `AND action = @action`, quirk.Map{AuditAction: filter.Action}).
+		If(len(filter.Target) > 0, `AND target = @target`, quirk.Map{AuditTarget: filter.Target}).
+		If(filter.UserId > 0, `AND user_id = @user_id`, quirk.Map{AuditUserId: filter.UserId}).
+		If(len(filter.Subject) > 0, `AND subject = @subject`, quirk.Map{AuditSubject: filter.Subject}).
+		If(len(filter.Ip) > 0, `AND ip = @ip`, quirk.Map{AuditIp: filter.Ip}).
+		If(len(filter.Route) > 0, `AND route = @route`, quirk.Map{AuditRoute: filter.Route}).
+		If(len(filter.RequestId) > 0, `AND request_id = @request_id`, quirk.Map{AuditRequestId: filter.RequestId}).
+		If(!filter.From.IsZero(), `AND created_at >= @from`, quirk.Map{"from": filter.From}).
+		If(!filter.To.IsZero(), `AND created_at < @to`, quirk.Map{"to": filter.To})
+}
+
+func createAuditEntry(c *handlerContext, action, target string, details map[string]any) config.AuditEntry {
+	entry := config.AuditEntry{
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		Ip:        c.request.Ip(),
+		UserAgent: c.request.UserAgent(),
+		RequestId: c.request.Id(),
+		CreatedAt: time.Now(),
+	}
+	if c.route != nil {
+		entry.Route = c.route.Name
+	}
+	if principal, err := c.Principal(); err == nil && (principal.Authenticated() || principal.Pending) {
+		entry.UserId = principal.Id
+		entry.Subject = principal.Kind + ":" + principal.Subject
+	}
+	return entry
+}
+
+func writeAudit(c *handlerContext, entry config.AuditEntry) error {
+	audit := c.config.Security.Audit
+	if !audit.Enabled {
+		return nil
+	}
+	if audit.Sink != nil {
+		return audit.Sink(entry)
+	}
+	return c.Audits(getAuditDatabase(audit)).Create(entry)
+}
+
+func validateAudit(audit config.Audit, databases map[string]*quirk.DB) {
+	if !audit.Enabled || audit.Sink != nil {
+		return
+	}
+	if _, ok := databases[getAuditDatabase(audit)]; !ok {
+		panic(fmt.Errorf("%w: %s", ErrorInvalidAudit, getAuditDatabase(audit)))
+	}
+}
+
+func auditRequest(c *handlerContext, err error) error {
+	statusCode := c.send.statusCode
+	if err != nil && statusCode == http.StatusOK {
+		statusCode = http.StatusInternalServerError
+	}
+	return writeAudit(
+		c, createAuditEntry(
+			c, c.req.Method+" "+c.route.Host+c.route.Path, c.req.URL.Path, map[string]any{
+				"status": statusCode,
+			},
+		),
+	)
+}
+
+func isAuditRoute(route *Route) bool {
+	return slices.Contains(auditMethods, route.Method) && slices.ContainsFunc(
+		route.Firewalls, func(firewall config.Firewall) bool {
+			return firewall.Audit
+		},
+	)
+}
+
+func getAuditDatabase(audit config.Audit) string {
+	if len(audit.Database) > 0 {
+		return audit.Database
+	}
+	return Main
+}
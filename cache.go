@@ -0,0 +1,85 @@
+package sense
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/creamsensation/cache"
+	"github.com/creamsensation/cache/memory"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type memoryCache struct {
+	client *memory.Client
+}
+
+type atomicCacheClient struct {
+	cache atomicCache
+}
+
+func createCache(ctx context.Context, cfg config.Cache, atomic atomicCache) cache.Client {
+	switch {
+	case cfg.Redis != nil:
+		return cache.New(ctx, cfg.Memory, cfg.Redis)
+	case cfg.Memory != nil:
+		return &memoryCache{client: cfg.Memory}
+	default:
+		return atomicCacheClient{cache: atomic}
+	}
+}
+
+func (m *memoryCache) Exists(key string) bool {
+	m.client.RLock()
+	defer m.client.RUnlock()
+	return m.client.Exists(key)
+}
+
+func (m *memoryCache) Get(key string, data any) error {
+	value := m.client.Get(key)
+	if len(value) == 0 {
+		m.client.Unlock()
+		return nil
+	}
+	return json.Unmarshal([]byte(value), data)
+}
+
+func (m *memoryCache) Set(key string, data any, expiration time.Duration) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return m.client.Set(key, string(bytes), expiration)
+}
+
+func (m *memoryCache) Destroy(key string) error {
+	m.client.Lock()
+	defer m.client.Unlock()
+	return m.client.Destroy(key)
+}
+
+func (c atomicCacheClient) Exists(key string) bool {
+	_, ok, err := c.cache.load(key)
+	return ok && err == nil
+}
+
+func (c atomicCacheClient) Get(key string, data any) error {
+	value, ok, err := c.cache.load(key)
+	if err != nil || !ok {
+		return err
+	}
+	return json.Unmarshal([]byte(value), data)
+}
+
+func (c atomicCacheClient) Set(key string, data any, expiration time.Duration) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.cache.store(key, string(bytes), expiration)
+}
+
+func (c atomicCacheClient) Destroy(key string) error {
+	return deleteAtomic(c.cache, key)
+}
@@ -0,0 +1,78 @@
+package sense
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/cache/memory"
+
+	"github.com/creamsensation/sense/config"
+)
+
+func TestCacheDefaultsToAppMemory(t *testing.T) {
+	first := createCache(context.Background(), config.Cache{}, createMemoryAtomicCache())
+	second := createCache(context.Background(), config.Cache{}, createMemoryAtomicCache())
+	if err := first.Set("key", map[string]int{"id": 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var value map[string]int
+	if err := first.Get("key", &value); err != nil || value["id"] != 1 || !first.Exists("key") {
+		t.Fatalf("expected stored value, got %v %v", value, err)
+	}
+	if second.Exists("key") {
+		t.Fatal("cache must not be shared between apps")
+	}
+	if err := first.Set("expired", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if first.Exists("expired") {
+		t.Fatal("expected value to expire")
+	}
+	if err := first.Destroy("key"); err != nil || first.Exists("key") {
+		t.Fatalf("expected value to be destroyed, got %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "concurrent-" + strconv.Itoa(i%2)
+			for j := 0; j < 100; j++ {
+				_ = first.Set(key, j, time.Minute)
+				_ = first.Exists(key)
+				_ = first.Get(key, new(int))
+				_ = first.Destroy(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMemoryCacheReleasesLockOnMiss(t *testing.T) {
+	client := createCache(context.Background(), config.Cache{Memory: memory.New(t.TempDir())}, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var value string
+		for i := 0; i < 2; i++ {
+			if err := client.Get("missing", &value); err != nil || len(value) > 0 {
+				t.Errorf("expected empty miss, got %q %v", value, err)
+			}
+		}
+		if client.Exists("missing") {
+			t.Error("expected missing key")
+		}
+		if err := client.Destroy("missing"); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache lock was not released")
+	}
+}
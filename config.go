@@ -16,6 +16,7 @@ type Config struct {
 	Export       config.Export
 	Filesystem   filesystem.Config
 	Localization config.Localization
+	Openapi      config.Openapi
 	Parser       config.Parser
 	Router       config.Router
 	Security     config.Security
@@ -0,0 +1,10 @@
+package config
+
+type Openapi struct {
+	Title           string
+	Version         string
+	Path            string
+	ViewerPath      string
+	ViewerScript    string
+	ViewerIntegrity string
+}
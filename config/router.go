@@ -1,6 +1,17 @@
 package config
 
 type Router struct {
-	Prefix  string
-	Recover bool
+	Prefix         string
+	Recover        bool
+	Quiet          bool
+	RoutesPath     string
+	TrailingSlash  string
+	TrustedProxies []string
 }
+
+const (
+	TrailingSlashStrip  = "strip"
+	TrailingSlashAppend = "append"
+	TrailingSlashIgnore = "ignore"
+	TrailingSlashBoth   = "both"
+)
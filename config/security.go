@@ -7,17 +7,195 @@ import (
 )
 
 type Security struct {
-	Auth      auth.Config
-	Csrf      Csrf
-	Firewalls []Firewall
+	Audit          Audit
+	Auth           auth.Config
+	Authentication Authentication
+	Cors           Cors
+	Csrf           Csrf
+	Firewalls      []Firewall
+	Headers        Headers
+	Login          Login
+	Oidc           map[string]OidcProvider
+	Permissions    map[string][]string
+	RateLimit      RateLimit
+	Tfa            Tfa
+}
+
+type Audit struct {
+	Enabled  bool
+	Database string
+	Sink     func(entry AuditEntry) error
+}
+
+type AuditEntry struct {
+	Id        int            `json:"id"`
+	Action    string         `json:"action"`
+	Target    string         `json:"target"`
+	Details   map[string]any `json:"details"`
+	UserId    int            `json:"userId"`
+	Subject   string         `json:"subject"`
+	Ip        string         `json:"ip"`
+	UserAgent string         `json:"userAgent"`
+	Route     string         `json:"route"`
+	RequestId string         `json:"requestId"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type Authentication struct {
+	ApiKeyHeader string
+	ApiKeys      []ApiKey
+	Tokens       Tokens
+	Jwt          Jwt
+}
+
+type OidcProvider struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	RedirectUrl  string
+}
+
+type ApiKey struct {
+	Name   string
+	Key    string
+	Roles  []string
+	Scopes []string
+}
+
+type Tokens struct {
+	Database string
+}
+
+type Jwt struct {
+	Jwks        string
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	IdClaim     string
+	Leeway      time.Duration
+	OptionalExp bool
+}
+
+const (
+	AuthenticationSession = "session"
+	AuthenticationApiKey  = "api-key"
+	AuthenticationToken   = "token"
+	AuthenticationJwt     = "jwt"
+)
+
+type Cors struct {
+	Enabled        bool
+	Origins        []string
+	Methods        []string
+	Headers        []string
+	ExposedHeaders []string
+	Credentials    bool
+	MaxAge         time.Duration
 }
 
 type Csrf struct {
-	Expiration time.Duration
+	Enabled      bool
+	Secret       string
+	Expiration   time.Duration
+	DoubleSubmit bool
+	Exempt       []string
 }
 
 type Firewall struct {
-	Enabled  bool
-	Patterns []string
-	Roles    []string
+	Enabled        bool
+	Patterns       []string
+	Roles          []string
+	Allow          []string
+	Deny           []string
+	Methods        []string
+	Windows        []FirewallWindow
+	Authentication []string
+	Audit          bool
 }
+
+type FirewallWindow struct {
+	Days     []time.Weekday
+	From     string
+	To       string
+	Location *time.Location
+}
+
+type Headers struct {
+	Enabled            bool
+	Hsts               time.Duration
+	HstsSubdomains     bool
+	Csp                string
+	CspReportOnly      bool
+	CspReportPath      string
+	CspReporter        func(report []byte)
+	FrameOptions       string
+	ReferrerPolicy     string
+	PermissionsPolicy  string
+	ContentTypeOptions string
+}
+
+type Login struct {
+	Enabled       bool
+	MaxAttempts   int
+	MaxIpAttempts int
+	Window        time.Duration
+	Lockout       time.Duration
+	MaxLockout    time.Duration
+	Delay         time.Duration
+	MaxDelay      time.Duration
+	Reporter      func(event LoginEvent)
+}
+
+type LoginEvent struct {
+	Type      string
+	Email     string
+	Ip        string
+	UserAgent string
+	Failures  int
+	Until     time.Time
+	Time      time.Time
+}
+
+const (
+	LoginEventSuccess    = "login.success"
+	LoginEventFailure    = "login.failure"
+	LoginEventLocked     = "login.locked"
+	LoginEventBlocked    = "login.blocked"
+	LoginEventUnlocked   = "login.unlocked"
+	LoginEventTfaPending = "login.tfa.pending"
+)
+
+type Tfa struct {
+	Issuer        string
+	Secret        string
+	Remember      time.Duration
+	RecoveryCodes int
+	Messages      TfaMessages
+}
+
+type TfaMessages struct {
+	Required    string
+	InvalidCode string
+}
+
+type RateLimit struct {
+	Enabled   bool
+	Algorithm string
+	Key       string
+	KeyHeader string
+	Limit     int
+	Burst     int
+	Window    time.Duration
+}
+
+const (
+	RateLimitTokenBucket   = "token-bucket"
+	RateLimitSlidingWindow = "sliding-window"
+)
+
+const (
+	RateLimitKeyIp      = "ip"
+	RateLimitKeySession = "session"
+	RateLimitKeyApiKey  = "api-key"
+)
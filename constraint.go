@@ -0,0 +1,113 @@
+package sense
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type RouteConstraint struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	match   func(value string) bool
+}
+
+const (
+	ConstraintInt   = "int"
+	ConstraintUint  = "uint"
+	ConstraintFloat = "float"
+	ConstraintBool  = "bool"
+	ConstraintAlpha = "alpha"
+	ConstraintAlnum = "alnum"
+	ConstraintSlug  = "slug"
+	ConstraintUuid  = "uuid"
+	ConstraintDate  = "date"
+)
+
+var (
+	constraintMatchers = map[string]func(value string) bool{
+		ConstraintInt:   createRegexpMatcher(`-?[0-9]+`),
+		ConstraintUint:  createRegexpMatcher(`[0-9]+`),
+		ConstraintFloat: createRegexpMatcher(`-?[0-9]+(\.[0-9]+)?`),
+		ConstraintBool:  createRegexpMatcher(`true|false|1|0`),
+		ConstraintAlpha: createRegexpMatcher(`[a-zA-Z]+`),
+		ConstraintAlnum: createRegexpMatcher(`[a-zA-Z0-9]+`),
+		ConstraintSlug:  createRegexpMatcher(`[a-z0-9]+(-[a-z0-9]+)*`),
+		ConstraintUuid:  createRegexpMatcher(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`),
+		ConstraintDate: func(value string) bool {
+			_, err := time.Parse(time.DateOnly, value)
+			return err == nil
+		},
+	}
+)
+
+func createRoutePath(path string) (string, []RouteConstraint) {
+	path = formatPath(path)
+	constraints := make([]RouteConstraint, 0)
+	var result strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '{' {
+			result.WriteByte(path[i])
+			continue
+		}
+		end := findWildcardEnd(path, i)
+		if end == -1 {
+			panic(ErrorInvalidConstraint)
+		}
+		wildcard := path[i+1 : end]
+		name, pattern, ok := strings.Cut(wildcard, ":")
+		result.WriteString("{" + name + "}")
+		i = end
+		if !ok {
+			continue
+		}
+		constraints = append(constraints, createRouteConstraint(strings.TrimSuffix(name, "..."), pattern))
+	}
+	return result.String(), constraints
+}
+
+func createRouteConstraint(name, pattern string) RouteConstraint {
+	if len(name) == 0 || len(pattern) == 0 {
+		panic(ErrorInvalidConstraint)
+	}
+	match, ok := constraintMatchers[pattern]
+	if !ok {
+		match = createRegexpMatcher(pattern)
+	}
+	return RouteConstraint{
+		Name:    name,
+		Pattern: pattern,
+		match:   match,
+	}
+}
+
+func createRegexpMatcher(pattern string) func(value string) bool {
+	r := regexp.MustCompile("^(?:" + pattern + ")$")
+	return r.MatchString
+}
+
+func findWildcardEnd(path string, start int) int {
+	depth := 0
+	for i := start; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func matchRouteConstraints(req *http.Request, constraints []RouteConstraint) bool {
+	for _, constraint := range constraints {
+		if !constraint.match(req.PathValue(constraint.Name)) {
+			return false
+		}
+	}
+	return true
+}
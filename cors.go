@@ -0,0 +1,129 @@
+package sense
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type cors struct {
+	config   config.Cors
+	any      bool
+	matchers []func(origin string) bool
+}
+
+type preflight struct {
+	handler http.HandlerFunc
+	cors    map[string]*cors
+}
+
+func createPreflight() *preflight {
+	return &preflight{
+		cors: make(map[string]*cors),
+	}
+}
+
+func (p *preflight) add(method string, c *cors) {
+	if _, ok := p.cors[method]; !ok {
+		p.cors[method] = c
+	}
+}
+
+func (p *preflight) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	if p.handler != nil {
+		p.handler(res, req)
+		return
+	}
+	createPreflightHandlerFunc(p.cors[strings.ToUpper(req.Header.Get(header.AccessControlRequestMethod))])(res, req)
+}
+
+func createCors(config config.Cors) *cors {
+	if !config.Enabled {
+		return nil
+	}
+	c := &cors{
+		config:   config,
+		matchers: make([]func(origin string) bool, 0),
+	}
+	for _, origin := range config.Origins {
+		switch {
+		case origin == "*":
+			c.any = true
+		case strings.HasPrefix(origin, "^"):
+			c.matchers = append(c.matchers, regexp.MustCompile(origin).MatchString)
+		case strings.Contains(origin, "*"):
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[^.]+`) + "$"
+			c.matchers = append(c.matchers, regexp.MustCompile(pattern).MatchString)
+		default:
+			c.matchers = append(
+				c.matchers, func(o string) bool {
+					return strings.EqualFold(o, origin)
+				},
+			)
+		}
+	}
+	return c
+}
+
+func (c *cors) allowed(origin string) bool {
+	if c.any {
+		return true
+	}
+	for _, match := range c.matchers {
+		if match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) apply(res http.ResponseWriter, req *http.Request) bool {
+	res.Header().Add(header.Vary, header.Origin)
+	origin := req.Header.Get(header.Origin)
+	if len(origin) == 0 || !c.allowed(origin) {
+		return false
+	}
+	if c.any && !c.config.Credentials {
+		res.Header().Set(header.AccessControlAllowOrigin, "*")
+	} else {
+		res.Header().Set(header.AccessControlAllowOrigin, origin)
+	}
+	if c.config.Credentials {
+		res.Header().Set(header.AccessControlAllowCredentials, "true")
+	}
+	if len(c.config.ExposedHeaders) > 0 {
+		res.Header().Set(header.AccessControlExposeHeaders, strings.Join(c.config.ExposedHeaders, ", "))
+	}
+	return true
+}
+
+func (c *cors) preflight(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add(header.Vary, header.AccessControlRequestMethod)
+	res.Header().Add(header.Vary, header.AccessControlRequestHeaders)
+	if !c.apply(res, req) {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+	methods := strings.Join(c.config.Methods, ", ")
+	if len(methods) == 0 {
+		methods = req.Header.Get(header.AccessControlRequestMethod)
+	}
+	if len(methods) > 0 {
+		res.Header().Set(header.AccessControlAllowMethods, methods)
+	}
+	headers := strings.Join(c.config.Headers, ", ")
+	if len(headers) == 0 {
+		headers = req.Header.Get(header.AccessControlRequestHeaders)
+	}
+	if len(headers) > 0 {
+		res.Header().Set(header.AccessControlAllowHeaders, headers)
+	}
+	if c.config.MaxAge > 0 {
+		res.Header().Set(header.AccessControlMaxAge, strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package sense
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+	
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/cookie"
+	
+	"github.com/creamsensation/sense/config"
+)
+
+type CsrfContext interface {
+	Token() string
+	Verify(token string) bool
+}
+
+type csrf struct {
+	config config.Csrf
+	cookie cookie.Cookie
+	secret string
+}
+
+const (
+	CsrfCookieKey = "X-Csrf"
+	CsrfHeaderKey = "X-Csrf-Token"
+	CsrfFieldKey  = "_csrf"
+)
+
+const (
+	csrfDuration    = 24 * time.Hour
+	csrfSecretBytes = 32
+)
+
+var (
+	csrfSafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+)
+
+func (c *csrf) Token() string {
+	secret := c.getSecret()
+	if c.config.DoubleSubmit {
+		return secret
+	}
+	return createCsrfToken(c.config.Secret, secret, c.cookie.Get(auth.SessionCookieKey))
+}
+
+func (c *csrf) Verify(token string) bool {
+	secret := c.cookie.Get(CsrfCookieKey)
+	if len(secret) == 0 || len(token) == 0 {
+		return false
+	}
+	expected := secret
+	if !c.config.DoubleSubmit {
+		expected = createCsrfToken(c.config.Secret, secret, c.cookie.Get(auth.SessionCookieKey))
+	}
+	return hmac.Equal([]byte(expected), []byte(token))
+}
+
+func (c *csrf) getSecret() string {
+	if len(c.secret) > 0 {
+		return c.secret
+	}
+	c.secret = c.cookie.Get(CsrfCookieKey)
+	if len(c.secret) > 0 {
+		return c.secret
+	}
+	bytes := make([]byte, csrfSecretBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	c.secret = base64.RawURLEncoding.EncodeToString(bytes)
+	expiration := c.config.Expiration
+	if expiration == 0 {
+		expiration = csrfDuration
+	}
+	c.cookie.Set(CsrfCookieKey, c.secret, expiration)
+	return c.secret
+}
+
+func createCsrfToken(key, secret, session string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(secret))
+	mac.Write([]byte{0})
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func createCsrfExempt(csrf config.Csrf) []*regexp.Regexp {
+	if csrf.Enabled && !csrf.DoubleSubmit && len(csrf.Secret) == 0 {
+		panic(ErrorInvalidCsrfSecret)
+	}
+	result := make([]*regexp.Regexp, len(csrf.Exempt))
+	for i, pattern := range csrf.Exempt {
+		result[i] = regexp.MustCompile(pattern)
+	}
+	return result
+}
+
+func isCsrfExemptPath(path string, exempt []*regexp.Regexp) bool {
+	for _, matcher := range exempt {
+		if matcher.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfMiddleware() Handler {
+	return func(c Context) error {
+		req := c.Request().Raw()
+		if slices.Contains(csrfSafeMethods, req.Method) {
+			return c.Continue()
+		}
+		token := req.Header.Get(CsrfHeaderKey)
+		if len(token) == 0 && (isRequestMultipart(req) || isRequestForm(req)) {
+			if isRequestMultipart(req) {
+				_ = req.ParseMultipartForm(c.Config().Parser.Limit << 20)
+			}
+			token = req.FormValue(CsrfFieldKey)
+		}
+		if !c.Csrf().Verify(token) {
+			return c.Send().Status(http.StatusForbidden).Error(ErrorInvalidCsrf)
+		}
+		return c.Continue()
+	}
+}
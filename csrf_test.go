@@ -0,0 +1,139 @@
+package sense
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
+	"github.com/creamsensation/sense/internal/constant/model"
+)
+
+type testCsrfRequest struct {
+	path    string
+	token   func(token, secret string) string
+	field   bool
+	session string
+}
+
+const (
+	testCsrfSecret  = "csrf-secret"
+	testCsrfSession = "session-1"
+)
+
+func TestCsrfMiddleware(t *testing.T) {
+	valid := func(token, secret string) string { return token }
+	tampered := func(token, secret string) string { return token + "x" }
+	tests := []struct {
+		name         string
+		doubleSubmit bool
+		request      testCsrfRequest
+		statusCode   int
+	}{
+		{"valid header", false, testCsrfRequest{path: "/submit", token: valid, session: testCsrfSession}, http.StatusOK},
+		{"valid form field", false, testCsrfRequest{path: "/submit", token: valid, field: true, session: testCsrfSession}, http.StatusOK},
+		{"missing token", false, testCsrfRequest{path: "/submit", session: testCsrfSession}, http.StatusForbidden},
+		{"tampered token", false, testCsrfRequest{path: "/submit", token: tampered, session: testCsrfSession}, http.StatusForbidden},
+		{"other session", false, testCsrfRequest{path: "/submit", token: valid, session: "session-2"}, http.StatusForbidden},
+		{"secret as token", false, testCsrfRequest{path: "/submit", token: func(token, secret string) string { return secret }, session: testCsrfSession}, http.StatusForbidden},
+		{"exempt route", false, testCsrfRequest{path: "/exempt", session: testCsrfSession}, http.StatusOK},
+		{"exempt pattern", false, testCsrfRequest{path: "/webhook/stripe", session: testCsrfSession}, http.StatusOK},
+		{"double submit", true, testCsrfRequest{path: "/submit", token: valid}, http.StatusOK},
+		{"double submit mismatch", true, testCsrfRequest{path: "/submit", token: tampered}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				app := createTestCsrfApp(test.doubleSubmit)
+				token, cookies := getTestCsrfToken(t, app, testCsrfSession)
+				if res := sendTestCsrfRequest(app, test.request, token, cookies); res.Code != test.statusCode {
+					t.Fatalf("expected %d, got %d %s", test.statusCode, res.Code, res.Body.String())
+				}
+			},
+		)
+	}
+}
+
+func TestCsrfRequiresSecret(t *testing.T) {
+	defer func() {
+		if recover() != ErrorInvalidCsrfSecret {
+			t.Fatal("expected missing secret to panic")
+		}
+	}()
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Security.Csrf = config.Csrf{Enabled: true}
+	New(cfg)
+}
+
+func createTestCsrfApp(doubleSubmit bool) Sense {
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Security.Csrf = config.Csrf{Enabled: true, Secret: testCsrfSecret, DoubleSubmit: doubleSubmit, Exempt: []string{"^/webhook/"}}
+	app := New(cfg)
+	app.Get(
+		"/token", func(c Context) error {
+			return c.Send().Text(c.Csrf().Token())
+		},
+	)
+	ok := func(c Context) error {
+		return c.Send().Text("ok")
+	}
+	app.Post("/submit", ok)
+	app.Post("/exempt", ok).CsrfExempt()
+	app.Post("/webhook/stripe", ok)
+	return app
+}
+
+func getTestCsrfToken(t *testing.T, app Sense, session string) (string, []*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookieKey, Value: session})
+	res := httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, req)
+	var body model.Json
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil || res.Code != http.StatusOK {
+		t.Fatalf("unexpected token response: %d %s", res.Code, res.Body.String())
+	}
+	token, _ := body.Result.(string)
+	return token, res.Result().Cookies()
+}
+
+func sendTestCsrfRequest(app Sense, request testCsrfRequest, token string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	var secret string
+	for _, cookie := range cookies {
+		if cookie.Name == CsrfCookieKey {
+			secret = cookie.Value
+		}
+	}
+	if request.token == nil {
+		token = ""
+	} else {
+		token = request.token(token, secret)
+	}
+	var req *http.Request
+	if request.field {
+		req = httptest.NewRequest(http.MethodPost, request.path, strings.NewReader(url.Values{CsrfFieldKey: {token}}.Encode()))
+		req.Header.Set(header.ContentType, contentType.Form)
+	} else {
+		req = httptest.NewRequest(http.MethodPost, request.path, nil)
+		if len(token) > 0 {
+			req.Header.Set(CsrfHeaderKey, token)
+		}
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if len(request.session) > 0 {
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieKey, Value: request.session})
+	}
+	res := httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, req)
+	return res
+}
@@ -4,6 +4,7 @@ import "errors"
 
 var (
 	ErrorInvalidDatabase   = errors.New("invalid database")
+	ErrorInvalidDriver     = errors.New("unsupported database driver")
 	ErrorInvalidWebsocket  = errors.New("invalid websocket")
 	ErrorInvalidLang       = errors.New("invalid lang")
 	ErrorInvalidMultipart  = errors.New("request has not multipart content type")
@@ -12,8 +13,46 @@ var (
 	ErrorPointerTarget     = errors.New("target must be a pointer")
 	ErrorQueryParamMissing = errors.New("query param is missing")
 	ErrorPathValueMissing  = errors.New("path value is missing")
+	ErrorInvalidValue      = errors.New("value cannot be parsed to target type")
+	ErrorUnsupportedType   = errors.New("target type is not supported")
+	ErrorInvalidConstraint = errors.New("invalid route constraint")
+	ErrorInvalidRouteName  = errors.New("invalid route name")
+	ErrorDuplicateRoute    = errors.New("route name already exists")
+	ErrorUrlParamMissing   = errors.New("url param is missing")
+	ErrorUrlParamInvalid   = errors.New("url param does not match route constraint")
+	ErrorDuplicatePattern  = errors.New("route pattern already registered")
+	ErrorInvalidCsrf       = errors.New("invalid csrf token")
+	ErrorInvalidCsrfSecret = errors.New("invalid csrf secret")
+	ErrorTooManyRequests   = errors.New("too many requests")
+	ErrorInvalidCache      = errors.New("invalid cache result")
+	ErrorInvalidProxy      = errors.New("invalid trusted proxy")
+	ErrorInvalidFirewall   = errors.New("invalid firewall rule")
+	ErrorForbidden         = errors.New("forbidden")
+	ErrorInvalidJwt        = errors.New("invalid jwt")
+	ErrorInvalidJwk        = errors.New("invalid jwk")
+	ErrorInvalidProvider   = errors.New("invalid oidc provider")
+	ErrorInvalidOidc       = errors.New("invalid oidc response")
+	ErrorTfaRequired       = errors.New("two-factor authentication required")
+	ErrorInvalidTfaCode    = errors.New("invalid two-factor code")
+	ErrorTfaNotEnrolled    = errors.New("two-factor authentication not enrolled")
+	ErrorInvalidTfaSecret  = errors.New("invalid two-factor secret")
+	ErrorLoginLocked       = errors.New("login temporarily locked")
+	ErrorInvalidAudit      = errors.New("audit requires a sink or database")
 )
 
 type ErrorsWrapper[T any] struct {
 	Errors T `json:"errors"`
 }
+
+type localizedError struct {
+	err     error
+	message string
+}
+
+func (e localizedError) Error() string {
+	return e.message
+}
+
+func (e localizedError) Unwrap() error {
+	return e.err
+}
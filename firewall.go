@@ -0,0 +1,123 @@
+package sense
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/creamsensation/sense/config"
+)
+
+const (
+	firewallClockLayout = "15:04"
+)
+
+type firewallRule struct {
+	methods []string
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	windows []config.FirewallWindow
+}
+
+func firewallMiddleware(rules []firewallRule) Handler {
+	return func(c Context) error {
+		ip := c.Request().Ip()
+		method := c.Request().Method()
+		now := time.Now()
+		for _, rule := range rules {
+			if len(rule.methods) > 0 && !slices.Contains(rule.methods, method) {
+				continue
+			}
+			if !isFirewallIpAllowed(ip, rule) || !isFirewallWindowOpen(now, rule.windows) {
+				return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+			}
+		}
+		return c.Continue()
+	}
+}
+
+func isFirewallIpAllowed(ip string, rule firewallRule) bool {
+	if containsIp(ip, rule.deny) {
+		return false
+	}
+	return len(rule.allow) == 0 || containsIp(ip, rule.allow)
+}
+
+func isFirewallWindowOpen(now time.Time, windows []config.FirewallWindow) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		t := now
+		if window.Location != nil {
+			t = now.In(window.Location)
+		}
+		if len(window.Days) > 0 && !slices.Contains(window.Days, t.Weekday()) {
+			continue
+		}
+		from, to, err := parseFirewallWindow(window)
+		if err != nil {
+			return false
+		}
+		minutes := t.Hour()*60 + t.Minute()
+		if from <= to && minutes >= from && minutes < to {
+			return true
+		}
+		if from > to && (minutes >= from || minutes < to) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFirewallWindow(window config.FirewallWindow) (int, int, error) {
+	from, to := 0, 24*60
+	if len(window.From) > 0 {
+		t, err := time.Parse(firewallClockLayout, window.From)
+		if err != nil {
+			return 0, 0, err
+		}
+		from = t.Hour()*60 + t.Minute()
+	}
+	if len(window.To) > 0 {
+		t, err := time.Parse(firewallClockLayout, window.To)
+		if err != nil {
+			return 0, 0, err
+		}
+		to = t.Hour()*60 + t.Minute()
+	}
+	return from, to, nil
+}
+
+func createFirewallRules(firewalls []config.Firewall) []firewallRule {
+	result := make([]firewallRule, 0)
+	for _, firewall := range firewalls {
+		if len(firewall.Allow) == 0 && len(firewall.Deny) == 0 && len(firewall.Windows) == 0 {
+			continue
+		}
+		allow, err := parseIpNets(firewall.Allow)
+		if err != nil {
+			panic(fmt.Errorf("%w: %w", ErrorInvalidFirewall, err))
+		}
+		deny, err := parseIpNets(firewall.Deny)
+		if err != nil {
+			panic(fmt.Errorf("%w: %w", ErrorInvalidFirewall, err))
+		}
+		for _, window := range firewall.Windows {
+			if _, _, err := parseFirewallWindow(window); err != nil {
+				panic(fmt.Errorf("%w: %w", ErrorInvalidFirewall, err))
+			}
+		}
+		result = append(
+			result, firewallRule{
+				methods: firewall.Methods,
+				allow:   allow,
+				deny:    deny,
+				windows: firewall.Windows,
+			},
+		)
+	}
+	return result
+}
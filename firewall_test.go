@@ -0,0 +1,104 @@
+package sense
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/sense/config"
+)
+
+func TestFirewallIpRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		firewall config.Firewall
+		ip       string
+		allowed  bool
+	}{
+		{"allowed cidr", config.Firewall{Allow: []string{"10.0.0.0/8"}}, "10.1.2.3", true},
+		{"outside allowed cidr", config.Firewall{Allow: []string{"10.0.0.0/8"}}, "192.0.2.1", false},
+		{"allowed single ip", config.Firewall{Allow: []string{"192.0.2.1"}}, "192.0.2.1", true},
+		{"allowed single ip is exact", config.Firewall{Allow: []string{"192.0.2.1"}}, "192.0.2.2", false},
+		{"denied ip", config.Firewall{Deny: []string{"192.0.2.0/24"}}, "192.0.2.9", false},
+		{"not denied ip", config.Firewall{Deny: []string{"192.0.2.0/24"}}, "198.51.100.1", true},
+		{"deny wins over allow", config.Firewall{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}}, "10.0.0.1", false},
+		{"ipv6 allowed", config.Firewall{Allow: []string{"2001:db8::/32"}}, "2001:db8::1", true},
+		{"ipv6 outside allowed", config.Firewall{Allow: []string{"2001:db8::/32"}}, "2001:db9::1", false},
+		{"invalid ip with allow list", config.Firewall{Allow: []string{"10.0.0.0/8"}}, "not-an-ip", false},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				rules := createFirewallRules([]config.Firewall{test.firewall})
+				if len(rules) != 1 {
+					t.Fatalf("expected 1 rule, got %d", len(rules))
+				}
+				if result := isFirewallIpAllowed(test.ip, rules[0]); result != test.allowed {
+					t.Fatalf("expected %v, got %v", test.allowed, result)
+				}
+			},
+		)
+	}
+}
+
+func TestFirewallWindows(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skip(err)
+	}
+	monday := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		windows []config.FirewallWindow
+		now     time.Time
+		open    bool
+	}{
+		{"no windows", nil, monday, true},
+		{"inside window", []config.FirewallWindow{{From: "09:00", To: "17:00"}}, monday.Add(9 * time.Hour), true},
+		{"end is exclusive", []config.FirewallWindow{{From: "09:00", To: "17:00"}}, monday.Add(17 * time.Hour), false},
+		{"before window", []config.FirewallWindow{{From: "09:00", To: "17:00"}}, monday.Add(8*time.Hour + 59*time.Minute), false},
+		{"overnight window late", []config.FirewallWindow{{From: "22:00", To: "06:00"}}, monday.Add(23 * time.Hour), true},
+		{"overnight window early", []config.FirewallWindow{{From: "22:00", To: "06:00"}}, monday.Add(5 * time.Hour), true},
+		{"overnight window closed", []config.FirewallWindow{{From: "22:00", To: "06:00"}}, monday.Add(12 * time.Hour), false},
+		{"open ended from", []config.FirewallWindow{{From: "18:00"}}, monday.Add(23*time.Hour + 59*time.Minute), true},
+		{"open ended to", []config.FirewallWindow{{To: "06:00"}}, monday.Add(7 * time.Hour), false},
+		{"allowed day", []config.FirewallWindow{{Days: []time.Weekday{time.Monday}}}, monday.Add(12 * time.Hour), true},
+		{"other day", []config.FirewallWindow{{Days: []time.Weekday{time.Saturday, time.Sunday}}}, monday.Add(12 * time.Hour), false},
+		{"any matching window", []config.FirewallWindow{{Days: []time.Weekday{time.Sunday}}, {From: "10:00", To: "11:00"}}, monday.Add(10 * time.Hour), true},
+		{"location shifts the clock", []config.FirewallWindow{{From: "09:00", To: "17:00", Location: prague}}, monday.Add(8*time.Hour + 30*time.Minute), true},
+		{"location shifts the day", []config.FirewallWindow{{Days: []time.Weekday{time.Monday}, Location: prague}}, monday.Add(-30 * time.Minute), true},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				if result := isFirewallWindowOpen(test.now, test.windows); result != test.open {
+					t.Fatalf("expected %v, got %v", test.open, result)
+				}
+			},
+		)
+	}
+}
+
+func TestCreateFirewallRulesRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		firewall config.Firewall
+	}{
+		{"invalid allow", config.Firewall{Allow: []string{"10.0.0.0/33"}}},
+		{"invalid deny", config.Firewall{Deny: []string{"not-an-ip"}}},
+		{"invalid window", config.Firewall{Windows: []config.FirewallWindow{{From: "25:00"}}}},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				defer func() {
+					err, ok := recover().(error)
+					if !ok || !errors.Is(err, ErrorInvalidFirewall) {
+						t.Fatalf("expected %v panic, got %v", ErrorInvalidFirewall, err)
+					}
+				}()
+				createFirewallRules([]config.Firewall{test.firewall})
+			},
+		)
+	}
+}
@@ -3,6 +3,7 @@ package sense
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"sync"
 	
 	"github.com/creamsensation/filesystem"
@@ -17,35 +18,57 @@ import (
 )
 
 type Context interface {
+	Audit(action, target string, details map[string]any) error
+	Audits(dbname ...string) AuditManager
 	Auth(dbname ...string) auth.Manager
 	Cache() cache.Client
+	Can(permission string) bool
 	Cookie() cookie.Cookie
 	Config() Config
 	Continue() error
+	Csrf() CsrfContext
 	Db(dbname ...string) *quirk.Quirk
 	Email() mailer.Mailer
 	Export() ExportContext
 	Files() filesystem.Client
 	Lang() LangContext
+	Login() LoginContext
+	Nonce() string
 	Parse() ParseContext
+	Principal() (Principal, error)
 	Request() RequestContext
 	Send() SendContext
+	Sessions() SessionContext
+	Tfa() TfaContext
+	Tokens(dbname ...string) TokenManager
 	Translate(key string, args ...map[string]any) string
+	Url(name string, params map[string]any, query ...url.Values) string
+	AbsoluteUrl(name string, params map[string]any, query ...url.Values) string
 	Validate(s validator.Schema, data any) (bool, ErrorsWrapper[validator.Errors])
+	Ws() WsContext
 }
 
 type handlerContext struct {
 	context.Context
-	config  Config
-	res     http.ResponseWriter
-	req     *http.Request
-	mu      *sync.Mutex
-	cookie  cookie.Cookie
-	files   filesystem.Client
-	lang    lang
-	parse   *parser
-	request *request
-	send    *sender
+	config    Config
+	res       http.ResponseWriter
+	req       *http.Request
+	mu        *sync.Mutex
+	atomic    atomicCache
+	cookie    cookie.Cookie
+	csrf      *csrf
+	files     filesystem.Client
+	lang      lang
+	login     *login
+	nonce     string
+	parse     *parser
+	principal *Principal
+	request   *request
+	route     *Route
+	routes    *[]*Route
+	send      *sender
+	sessions  *sessions
+	tfa       *tfa
 }
 
 func createHandlerContext(args handlerContextArgs) *handlerContext {
@@ -56,23 +79,54 @@ func createHandlerContext(args handlerContextArgs) *handlerContext {
 		res:     args.res,
 		req:     args.req,
 		mu:      &sync.Mutex{},
+		atomic:  args.atomic,
 		cookie:  cookie.New(args.req, args.res, formatPath(args.config.Router.Prefix)+"/"),
 		files:   filesystem.New(ctx, args.config.Filesystem),
 		parse:   &parser{req: args.req, limit: args.config.Parser.Limit},
-		request: &request{req: args.req},
+		request: &request{req: args.req, proxies: args.proxies},
+		route:   args.route,
+		routes:  args.routes,
 	}
 	hc.lang = lang{config: args.config.Localization, cookie: hc.cookie}
+	hc.csrf = &csrf{config: args.config.Security.Csrf, cookie: hc.cookie}
+	hc.login = &login{ctx: hc}
+	hc.sessions = &sessions{ctx: hc}
+	hc.tfa = &tfa{ctx: hc}
+	if args.config.Security.Headers.Enabled {
+		hc.nonce = getRequestNonce(args.req)
+	}
+	if args.config.Security.Headers.Enabled && len(hc.nonce) == 0 {
+		hc.nonce = createNonce()
+		applySecureHeaders(args.res, args.config.Router, args.config.Security.Headers, hc.nonce)
+	}
 	hc.send = &sender{
 		request:    hc.request,
 		res:        args.res,
 		statusCode: http.StatusOK,
 		ws:         args.ws,
-		auth:       hc.Auth(),
+		conn:       args.conn,
+		principal:  hc.Principal,
 	}
 	hc.Lang().CreateIfNotExists()
 	return hc
 }
 
+func (c *handlerContext) Audit(action, target string, details map[string]any) error {
+	return writeAudit(c, createAuditEntry(c, action, target, details))
+}
+
+func (c *handlerContext) Audits(dbname ...string) AuditManager {
+	dbn := Main
+	if len(dbname) > 0 {
+		dbn = dbname[0]
+	}
+	db, ok := c.config.Database[dbn]
+	if !ok {
+		panic(ErrorInvalidDatabase)
+	}
+	return createAuditManager(db)
+}
+
 func (c *handlerContext) Auth(dbname ...string) auth.Manager {
 	var db *quirk.DB
 	var ok bool
@@ -97,7 +151,15 @@ func (c *handlerContext) Auth(dbname ...string) auth.Manager {
 }
 
 func (c *handlerContext) Cache() cache.Client {
-	return cache.New(c.Context, c.config.Cache.Memory, c.config.Cache.Redis)
+	return createCache(c.Context, c.config.Cache, c.atomic)
+}
+
+func (c *handlerContext) Can(permission string) bool {
+	principal, err := c.Principal()
+	if err != nil {
+		return false
+	}
+	return principalCan(principal, c.config.Security.Permissions, permission)
 }
 
 func (c *handlerContext) Config() Config {
@@ -113,6 +175,10 @@ func (c *handlerContext) Continue() error {
 	return nil
 }
 
+func (c *handlerContext) Csrf() CsrfContext {
+	return c.csrf
+}
+
 func (c *handlerContext) Db(dbname ...string) *quirk.Quirk {
 	dbn := Main
 	if len(dbname) > 0 {
@@ -141,10 +207,30 @@ func (c *handlerContext) Lang() LangContext {
 	return c.lang
 }
 
+func (c *handlerContext) Login() LoginContext {
+	return c.login
+}
+
+func (c *handlerContext) Nonce() string {
+	return c.nonce
+}
+
 func (c *handlerContext) Parse() ParseContext {
 	return c.parse
 }
 
+func (c *handlerContext) Principal() (Principal, error) {
+	if c.principal != nil {
+		return *c.principal, nil
+	}
+	principal, err := resolvePrincipal(c)
+	if err != nil {
+		return principal, err
+	}
+	c.principal = &principal
+	return principal, nil
+}
+
 func (c *handlerContext) Request() RequestContext {
 	return c.request
 }
@@ -153,6 +239,26 @@ func (c *handlerContext) Send() SendContext {
 	return c.send
 }
 
+func (c *handlerContext) Sessions() SessionContext {
+	return c.sessions
+}
+
+func (c *handlerContext) Tfa() TfaContext {
+	return c.tfa
+}
+
+func (c *handlerContext) Tokens(dbname ...string) TokenManager {
+	dbn := Main
+	if len(dbname) > 0 {
+		dbn = dbname[0]
+	}
+	db, ok := c.config.Database[dbn]
+	if !ok {
+		panic(ErrorInvalidDatabase)
+	}
+	return createTokenManager(db)
+}
+
 func (c *handlerContext) Translate(key string, args ...map[string]any) string {
 	if !c.config.Localization.Enabled {
 		return key
@@ -160,6 +266,26 @@ func (c *handlerContext) Translate(key string, args ...map[string]any) string {
 	return c.config.Localization.Translator.Translate(c.Lang().Get(), key, args...)
 }
 
+func (c *handlerContext) Url(name string, params map[string]any, query ...url.Values) string {
+	route := findRouteWithName(name, *c.routes)
+	if route == nil {
+		panic(ErrorInvalidRouteName)
+	}
+	return getForwardedPrefix(c.req, c.request.proxies) + createRouteUrl(route, c.config.Router.TrailingSlash, params, query...)
+}
+
+func (c *handlerContext) AbsoluteUrl(name string, params map[string]any, query ...url.Values) string {
+	route := findRouteWithName(name, *c.routes)
+	if route == nil {
+		panic(ErrorInvalidRouteName)
+	}
+	host := createRouteHost(route, params)
+	if len(host) == 0 {
+		host = c.request.getForwarded().host
+	}
+	return c.request.Protocol() + "://" + host + c.Url(name, params, query...)
+}
+
 func (c *handlerContext) Validate(s validator.Schema, data any) (bool, ErrorsWrapper[validator.Errors]) {
 	m := c.config.Localization.Validator
 	var messages validator.Messages
@@ -191,3 +317,7 @@ func (c *handlerContext) Validate(s validator.Schema, data any) (bool, ErrorsWra
 	ok, errs := v.Json(s, data)
 	return ok, ErrorsWrapper[validator.Errors]{errs}
 }
+
+func (c *handlerContext) Ws() WsContext {
+	return createWsContext(c.send.conn)
+}
@@ -3,9 +3,15 @@ package sense
 import (
 	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
 	
+	"github.com/creamsensation/auth"
+	
+	"github.com/creamsensation/sense/config"
 	"github.com/creamsensation/sense/internal/constant/contentType"
 	"github.com/creamsensation/sense/internal/constant/dataType"
 	"github.com/creamsensation/sense/internal/constant/header"
@@ -17,19 +23,30 @@ func createHandlerFunc(args handlerFuncArgs) func(
 	http.ResponseWriter, *http.Request,
 ) {
 	return func(res http.ResponseWriter, req *http.Request) {
+		if !matchRouteConstraints(req, args.route.Constraints) {
+			http.NotFound(res, req)
+			return
+		}
+		if args.cors != nil {
+			args.cors.apply(res, req)
+		}
 		var err error
 		c := createHandlerContext(
 			handlerContextArgs{
-				config: args.config,
-				req:    req,
-				res:    res,
+				config:  args.config,
+				req:     req,
+				res:     res,
+				route:   args.route,
+				routes:  args.routes,
+				proxies: args.proxies,
+				atomic:  args.atomic,
 			},
 		)
 		if args.config.Router.Recover {
 			defer createRecover(res)
 		}
-		args.middlewares = applyInternalMiddlewares(args.route, args.middlewares)
-		for _, middleware := range args.middlewares {
+		middlewares := applyInternalMiddlewares(args.config, args.route, args.middlewares, args.atomic)
+		for _, middleware := range middlewares {
 			c.mu.Lock()
 			err = middleware(c)
 			if err != nil {
@@ -37,10 +54,18 @@ func createHandlerFunc(args handlerFuncArgs) func(
 				createHandlerResponse(c, err)
 				return
 			}
+			if len(c.send.dataType) > 0 {
+				break
+			}
 		}
 		if len(c.send.dataType) == 0 {
 			err = args.handler(c)
 		}
+		if args.config.Security.Audit.Enabled && isAuditRoute(args.route) {
+			if auditErr := auditRequest(c, err); auditErr != nil {
+				log.Printf("audit: %s\n", auditErr)
+			}
+		}
 		createHandlerResponse(c, err)
 	}
 }
@@ -49,39 +74,115 @@ func createWsHandlerFunc(args handlerFuncArgs) func(
 	http.ResponseWriter, *http.Request,
 ) {
 	return func(res http.ResponseWriter, req *http.Request) {
+		if !matchRouteConstraints(req, args.route.Constraints) {
+			http.NotFound(res, req)
+			return
+		}
 		var err error
 		c := createHandlerContext(
 			handlerContextArgs{
-				config: args.config,
-				req:    req,
-				res:    res,
-				ws:     args.ws,
+				config:  args.config,
+				req:     req,
+				res:     res,
+				route:   args.route,
+				routes:  args.routes,
+				proxies: args.proxies,
+				atomic:  args.atomic,
+				ws:      args.ws,
 			},
 		)
 		if args.config.Router.Recover {
 			defer createRecover(res)
 		}
-		args.middlewares = applyInternalMiddlewares(args.route, args.middlewares)
-		for _, middleware := range args.middlewares {
+		middlewares := applyInternalMiddlewares(args.config, args.route, args.middlewares, args.atomic)
+		for _, middleware := range middlewares {
 			c.mu.Lock()
 			err = middleware(c)
 			if err != nil {
 				c.mu.Unlock()
 				return
 			}
+			if len(c.send.dataType) > 0 {
+				createHandlerResponse(c, nil)
+				return
+			}
 		}
 		var id int
-		if err := args.ws[args.name].OnRead(
-			func(bytes []byte) {
-				c.parse.bytes = bytes
-				if err := args.handler(c); err != nil {
-					panic(err)
-				}
-				c.parse.bytes = nil
+		var session, token string
+		if principal, err := c.Principal(); err == nil && principal.Authenticated() && principal.Kind == config.AuthenticationSession {
+			id = principal.Id
+			session = c.sessions.Current()
+			token = c.cookie.Get(auth.SessionCookieKey)
+		}
+		wsReq := createWsRequest(req)
+		client := createCache(wsReq.Context(), args.config.Cache, args.atomic)
+		_ = args.ws[args.name].serve(
+			res, req, id, session, wsEvents{
+				connect: func(conn *wsConn) {
+					if err := handleWsEvent(c, wsReq, args, conn, nil, args.wsOptions.OnConnect); err != nil {
+						conn.close()
+					}
+				},
+				message: func(conn *wsConn, bytes []byte) {
+					if len(token) > 0 && !isSessionActive(client, token) {
+						conn.close()
+						return
+					}
+					_ = handleWsEvent(c, wsReq, args, conn, bytes, args.handler)
+				},
+				disconnect: func(conn *wsConn) {
+					_ = handleWsEvent(c, wsReq, args, conn, nil, args.wsOptions.OnDisconnect)
+				},
 			},
-		).Serve(req, res, id); err != nil {
-			panic(err)
+		)
+	}
+}
+
+func handleWsEvent(c *handlerContext, req *http.Request, args handlerFuncArgs, conn *wsConn, bytes []byte, handler Handler) (err error) {
+	if handler == nil {
+		return nil
+	}
+	wc := createHandlerContext(
+		handlerContextArgs{
+			config:  args.config,
+			req:     req,
+			res:     createWsResponseWriter(),
+			route:   args.route,
+			routes:  args.routes,
+			proxies: args.proxies,
+			atomic:  args.atomic,
+			ws:      args.ws,
+			conn:    conn,
+		},
+	)
+	wc.principal = c.principal
+	wc.parse.bytes = bytes
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprintf("%v", e))
+		}
+		if err == nil {
+			return
+		}
+		if args.wsOptions.OnError != nil {
+			args.wsOptions.OnError(wc, err)
+			return
 		}
+		if errorBytes, wrapErr := wrapError(err); wrapErr == nil {
+			conn.send(errorBytes)
+		}
+	}()
+	return handler(wc)
+}
+
+func createPreflightHandlerFunc(cors *cors) func(
+	http.ResponseWriter, *http.Request,
+) {
+	if cors == nil {
+		return createEmptyHandlerResponse()
+	}
+	return func(res http.ResponseWriter, req *http.Request) {
+		cors.preflight(res, req)
 	}
 }
 
@@ -93,13 +194,30 @@ func createEmptyHandlerResponse() func(
 	}
 }
 
-func createHandlerCanonicalRedirect() func(http.ResponseWriter, *http.Request) {
+func createTrailingSlashRedirect(policy string, proxies []*net.IPNet) func(http.ResponseWriter, *http.Request) {
 	return func(res http.ResponseWriter, req *http.Request) {
-		uri := req.Header.Get("X-Forwarded-Uri")
+		var uri string
+		if isRequestFromTrustedProxy(req, proxies) {
+			uri = req.Header.Get(header.ForwardedUri)
+		}
 		if len(uri) == 0 {
 			uri = req.RequestURI
 		}
-		http.Redirect(res, req, strings.TrimSuffix(uri, "/"), http.StatusMovedPermanently)
+		path, query, ok := strings.Cut(uri, "?")
+		switch policy {
+		case config.TrailingSlashAppend:
+			path = path + "/"
+		default:
+			path = strings.TrimSuffix(path, "/")
+		}
+		if ok {
+			path += "?" + query
+		}
+		statusCode := http.StatusMovedPermanently
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			statusCode = http.StatusPermanentRedirect
+		}
+		http.Redirect(res, req, path, statusCode)
 	}
 }
 
@@ -159,10 +277,22 @@ func createRecover(res http.ResponseWriter) {
 	}
 }
 
-func applyInternalMiddlewares(route Route, middlewares []Handler) []Handler {
+func applyInternalMiddlewares(config Config, route *Route, middlewares []Handler, cache atomicCache) []Handler {
+	middlewares = slices.Clone(middlewares)
+	if rateLimit, ok := getRouteRateLimit(route, config.Security.RateLimit); ok {
+		middlewares = append(middlewares, rateLimitMiddleware(route, rateLimit, cache))
+	}
+	if len(route.firewallRules) > 0 {
+		middlewares = append(middlewares, firewallMiddleware(route.firewallRules))
+	}
 	if len(route.Firewalls) > 0 {
-		middlewares = append(middlewares, trailingSlashMiddleware())
 		middlewares = append(middlewares, authMiddleware(route.Firewalls))
 	}
+	if len(route.Permissions) > 0 || len(route.Policies) > 0 {
+		middlewares = append(middlewares, permissionMiddleware(route))
+	}
+	if config.Security.Csrf.Enabled && !route.CsrfExempt {
+		middlewares = append(middlewares, csrfMiddleware())
+	}
 	return middlewares
 }
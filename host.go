@@ -0,0 +1,110 @@
+package sense
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type hostDispatcher struct {
+	mu       *sync.RWMutex
+	handlers []hostHandler
+}
+
+type hostHandler struct {
+	host    string
+	matcher *regexp.Regexp
+	names   []string
+	handler http.HandlerFunc
+}
+
+func createHostDispatcher() *hostDispatcher {
+	return &hostDispatcher{
+		mu:       &sync.RWMutex{},
+		handlers: make([]hostHandler, 0),
+	}
+}
+
+func (d *hostDispatcher) add(host string, handler http.HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, item := range d.handlers {
+		if item.host == host {
+			panic(ErrorDuplicatePattern)
+		}
+	}
+	h := hostHandler{host: host, handler: handler}
+	if len(host) > 0 {
+		h.matcher, h.names = createHostMatcher(host)
+	}
+	d.handlers = append(d.handlers, h)
+}
+
+func (d *hostDispatcher) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	host := getRequestHostname(req)
+	var fallback http.HandlerFunc
+	for _, item := range d.handlers {
+		if item.matcher == nil {
+			fallback = item.handler
+			continue
+		}
+		matches := item.matcher.FindStringSubmatch(host)
+		if matches == nil {
+			continue
+		}
+		for i, name := range item.names {
+			req.SetPathValue(name, matches[i+1])
+		}
+		item.handler(res, req)
+		return
+	}
+	if fallback != nil {
+		fallback(res, req)
+		return
+	}
+	http.NotFound(res, req)
+}
+
+func createHostMatcher(host string) (*regexp.Regexp, []string) {
+	var pattern strings.Builder
+	names := make([]string, 0)
+	pattern.WriteString("^")
+	for len(host) > 0 {
+		start := strings.IndexByte(host, '{')
+		if start == -1 {
+			pattern.WriteString(regexp.QuoteMeta(host))
+			break
+		}
+		end := strings.IndexByte(host[start:], '}') + start
+		pattern.WriteString(regexp.QuoteMeta(host[:start]))
+		pattern.WriteString("([^.]+)")
+		names = append(names, host[start+1:end])
+		host = host[end+1:]
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile("(?i)" + pattern.String()), names
+}
+
+func createHostPattern(pattern, host string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return host + pattern
+	}
+	return method + " " + host + path
+}
+
+func isHostWildcard(host string) bool {
+	return strings.Contains(host, "{")
+}
+
+func getRequestHostname(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		return req.Host
+	}
+	return host
+}
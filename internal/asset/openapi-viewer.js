@@ -0,0 +1,131 @@
+(function () {
+  var root = document.getElementById("openapi");
+  var methods = ["get", "put", "post", "patch", "delete", "head", "options"];
+
+  function element(tag, className, text) {
+    var el = document.createElement(tag);
+    if (className) {
+      el.className = className;
+    }
+    if (text !== undefined) {
+      el.textContent = text;
+    }
+    return el;
+  }
+
+  function resolve(spec, schema) {
+    var seen = 0;
+    while (schema && schema.$ref && seen < 32) {
+      var path = schema.$ref.replace(/^#\//, "").split("/");
+      schema = path.reduce(function (value, key) {
+        return value ? value[key] : undefined;
+      }, spec);
+      seen++;
+    }
+    return schema;
+  }
+
+  function expand(spec, schema, depth) {
+    schema = resolve(spec, schema);
+    if (!schema || typeof schema !== "object" || depth > 8) {
+      return schema;
+    }
+    var result = Array.isArray(schema) ? [] : {};
+    Object.keys(schema).forEach(function (key) {
+      var value = schema[key];
+      result[key] = value && typeof value === "object" ? expand(spec, value, depth + 1) : value;
+    });
+    return result;
+  }
+
+  function schemaBlock(spec, title, schema) {
+    var block = element("div", "block");
+    block.appendChild(element("h4", "", title));
+    block.appendChild(element("pre", "", JSON.stringify(expand(spec, schema, 0), null, 2)));
+    return block;
+  }
+
+  function content(spec, value) {
+    var body = resolve(spec, value) || {};
+    var media = body.content || {};
+    var type = Object.keys(media)[0];
+    return type ? media[type].schema : undefined;
+  }
+
+  function operation(spec, path, method, op) {
+    var section = element("details", "operation " + method);
+    var summary = element("summary");
+    summary.appendChild(element("span", "method", method.toUpperCase()));
+    summary.appendChild(element("span", "path", path));
+    if (op.summary) {
+      summary.appendChild(element("span", "summary", op.summary));
+    }
+    section.appendChild(summary);
+    var parameters = (op.parameters || []).map(function (parameter) {
+      return resolve(spec, parameter);
+    });
+    if (parameters.length > 0) {
+      var table = element("table");
+      parameters.forEach(function (parameter) {
+        var row = element("tr");
+        row.appendChild(element("td", "", parameter.name));
+        row.appendChild(element("td", "", parameter.in));
+        row.appendChild(element("td", "", (resolve(spec, parameter.schema) || {}).type || ""));
+        row.appendChild(element("td", "", parameter.required ? "required" : ""));
+        table.appendChild(row);
+      });
+      section.appendChild(element("h4", "", "Parameters"));
+      section.appendChild(table);
+    }
+    var request = content(spec, op.requestBody);
+    if (request) {
+      section.appendChild(schemaBlock(spec, "Request", request));
+    }
+    Object.keys(op.responses || {}).forEach(function (status) {
+      var response = resolve(spec, op.responses[status]) || {};
+      var schema = content(spec, response);
+      var title = status + (response.description ? " " + response.description : "");
+      section.appendChild(schema ? schemaBlock(spec, title, schema) : element("h4", "", title));
+    });
+    return section;
+  }
+
+  function render(spec) {
+    var info = spec.info || {};
+    root.textContent = "";
+    root.appendChild(element("h1", "", info.title || "API"));
+    if (info.version) {
+      root.appendChild(element("p", "version", info.version));
+    }
+    var groups = {};
+    Object.keys(spec.paths || {}).forEach(function (path) {
+      methods.forEach(function (method) {
+        var op = spec.paths[path][method];
+        if (!op) {
+          return;
+        }
+        var tag = (op.tags && op.tags[0]) || "default";
+        (groups[tag] = groups[tag] || []).push(operation(spec, path, method, op));
+      });
+    });
+    Object.keys(groups).sort().forEach(function (tag) {
+      root.appendChild(element("h2", "", tag));
+      groups[tag].forEach(function (section) {
+        root.appendChild(section);
+      });
+    });
+  }
+
+  fetch(root.getAttribute("data-spec-url"), { credentials: "same-origin" })
+    .then(function (res) {
+      if (!res.ok) {
+        throw new Error(res.status + " " + res.statusText);
+      }
+      return res.json();
+    })
+    .then(render)
+    .catch(function (err) {
+      root.textContent = "";
+      root.appendChild(element("p", "error", err.message));
+    });
+})();
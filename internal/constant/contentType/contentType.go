@@ -6,6 +6,7 @@ const (
 	Form          = "application/x-www-form-urlencoded"
 	MultipartForm = "multipart/form-data"
 	Json          = "application/json; charset=utf-8"
+	Javascript    = "text/javascript; charset=utf-8"
 	Xml           = "application/xml; charset=utf-8"
 	OctetStream   = "application/octet-stream; charset=utf-8"
 )
@@ -1,16 +1,47 @@
 package header
 
 const (
-	CacheControl       = "Cache-Control"
-	Cookie             = "cookie"
-	ContentType        = "Content-Type"
-	ContentDisposition = "Content-Disposition"
-	ContentLength      = "Content-Length"
-	ContentEncoding    = "Content-Encoding"
-	ETag               = "ETag"
-	IfNoneMatch        = "If-None-Match"
-	Ip                 = "X-Forwarded-For"
-	Origin             = "Origin"
-	SetCookie          = "Set-Cookie"
-	UserAgent          = "User-Agent"
+	Accept                          = "Accept"
+	AccessControlAllowCredentials   = "Access-Control-Allow-Credentials"
+	AccessControlAllowHeaders       = "Access-Control-Allow-Headers"
+	AccessControlAllowMethods       = "Access-Control-Allow-Methods"
+	AccessControlAllowOrigin        = "Access-Control-Allow-Origin"
+	AccessControlExposeHeaders      = "Access-Control-Expose-Headers"
+	AccessControlMaxAge             = "Access-Control-Max-Age"
+	AccessControlRequestHeaders     = "Access-Control-Request-Headers"
+	AccessControlRequestMethod      = "Access-Control-Request-Method"
+	AcceptEncoding                  = "Accept-Encoding"
+	Authorization                   = "Authorization"
+	CacheControl                    = "Cache-Control"
+	Cookie                          = "cookie"
+	ContentType                     = "Content-Type"
+	ContentDisposition              = "Content-Disposition"
+	ContentLength                   = "Content-Length"
+	ContentSecurityPolicy           = "Content-Security-Policy"
+	ContentSecurityPolicyReportOnly = "Content-Security-Policy-Report-Only"
+	ContentEncoding                 = "Content-Encoding"
+	ETag                            = "ETag"
+	Forwarded                       = "Forwarded"
+	ForwardedHost                   = "X-Forwarded-Host"
+	ForwardedPrefix                 = "X-Forwarded-Prefix"
+	ForwardedProto                  = "X-Forwarded-Proto"
+	ForwardedUri                    = "X-Forwarded-Uri"
+	IfNoneMatch                     = "If-None-Match"
+	Ip                              = "X-Forwarded-For"
+	Origin                          = "Origin"
+	PermissionsPolicy               = "Permissions-Policy"
+	RateLimitLimit                  = "RateLimit-Limit"
+	RateLimitPolicy                 = "RateLimit-Policy"
+	RateLimitRemaining              = "RateLimit-Remaining"
+	RateLimitReset                  = "RateLimit-Reset"
+	ReferrerPolicy                  = "Referrer-Policy"
+	RetryAfter                      = "Retry-After"
+	SetCookie                       = "Set-Cookie"
+	StrictTransportSecurity         = "Strict-Transport-Security"
+	UserAgent                       = "User-Agent"
+	Vary                            = "Vary"
+	XApiKey                         = "X-Api-Key"
+	XContentTypeOptions             = "X-Content-Type-Options"
+	XFrameOptions                   = "X-Frame-Options"
+	XRequestId                      = "X-Request-Id"
 )
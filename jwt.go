@@ -0,0 +1,341 @@
+package sense
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+type jwksFileEntry struct {
+	mu      sync.Mutex
+	keys    []jwtKey
+	modTime time.Time
+	size    int64
+}
+
+const (
+	jwtRolesClaim = "roles"
+)
+
+var (
+	jwksCache = &sync.Map{}
+)
+
+func verifyJwt(token string, cfg config.Jwt) (map[string]any, error) {
+	keys, err := loadJwks(cfg.Jwks)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := verifyJwtWithKeys(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateJwtClaims(claims, cfg, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func verifyJwtWithKeys(token string, keys []jwtKey) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrorInvalidJwt
+	}
+	var h jwtHeader
+	if err := decodeJwtPart(parts[0], &h); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Join(ErrorInvalidJwt, err)
+	}
+	verified := false
+	for _, key := range keys {
+		if (len(h.Kid) > 0 && key.kid != h.Kid) || (len(key.alg) > 0 && key.alg != h.Alg) {
+			continue
+		}
+		if verifyJwtSignature(h.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrorInvalidJwt
+	}
+	claims := make(map[string]any)
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func verifyJwtSignature(alg string, key crypto.PublicKey, payload, signature []byte) bool {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		k, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(k, payload, signature)
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(k, hash, digest, signature, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+func validateJwtClaims(claims map[string]any, cfg config.Jwt, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok && !cfg.OptionalExp {
+		return ErrorInvalidJwt
+	}
+	if ok && now.After(time.Unix(int64(exp), 0).Add(cfg.Leeway)) {
+		return ErrorInvalidJwt
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-cfg.Leeway)) {
+		return ErrorInvalidJwt
+	}
+	if len(cfg.Issuer) > 0 && claims["iss"] != cfg.Issuer {
+		return ErrorInvalidJwt
+	}
+	if len(cfg.Audience) > 0 && !slices.Contains(getJwtClaimStrings(claims, "aud"), cfg.Audience) {
+		return ErrorInvalidJwt
+	}
+	return nil
+}
+
+func createJwtPrincipal(claims map[string]any, security config.Security) Principal {
+	rolesClaim := security.Authentication.Jwt.RolesClaim
+	if len(rolesClaim) == 0 {
+		rolesClaim = jwtRolesClaim
+	}
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	id := getJwtClaimId(claims, security.Authentication.Jwt.IdClaim)
+	roles := getJwtClaimStrings(claims, rolesClaim)
+	scopes := strings.Fields(strings.Join(getJwtClaimStrings(claims, "scope"), " "))
+	if len(scopes) == 0 {
+		scopes = getJwtClaimStrings(claims, "scp")
+	}
+	return Principal{
+		Session: auth.Session{
+			Id:    id,
+			Email: email,
+			Roles: roles,
+			Super: containsSuperRole(security.Auth, roles),
+		},
+		Kind:    config.AuthenticationJwt,
+		Subject: subject,
+		Scopes:  scopes,
+		Claims:  claims,
+	}
+}
+
+func getJwtClaimId(claims map[string]any, name string) int {
+	if len(name) == 0 {
+		return 0
+	}
+	var id int
+	switch v := claims[name].(type) {
+	case float64:
+		id = int(v)
+		if float64(id) != v {
+			return 0
+		}
+	case string:
+		id, _ = strconv.Atoi(v)
+	}
+	return max(id, 0)
+}
+
+func getJwtClaimStrings(claims map[string]any, name string) []string {
+	result := make([]string, 0)
+	switch v := claims[name].(type) {
+	case string:
+		result = append(result, v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+func decodeJwtPart(part string, target any) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.Join(ErrorInvalidJwt, err)
+	}
+	if err := json.Unmarshal(bytes, target); err != nil {
+		return errors.Join(ErrorInvalidJwt, err)
+	}
+	return nil
+}
+
+func loadJwks(path string) ([]jwtKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Join(ErrorReadData, err)
+	}
+	value, _ := jwksCache.LoadOrStore(path, &jwksFileEntry{})
+	entry := value.(*jwksFileEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.keys != nil && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.keys, nil
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Join(ErrorReadData, err)
+	}
+	keys, err := parseJwks(bytes)
+	if err != nil {
+		return nil, err
+	}
+	entry.keys = keys
+	entry.modTime = info.ModTime()
+	entry.size = info.Size()
+	return keys, nil
+}
+
+func parseJwks(bytes []byte) ([]jwtKey, error) {
+	var set jwks
+	if err := json.Unmarshal(bytes, &set); err != nil {
+		return nil, errors.Join(ErrorReadData, err)
+	}
+	keys := make([]jwtKey, 0, len(set.Keys))
+	for _, item := range set.Keys {
+		if len(item.Use) > 0 && item.Use != "sig" {
+			continue
+		}
+		key, err := parseJwk(item)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwtKey{kid: item.Kid, alg: item.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func parseJwk(item jwk) (crypto.PublicKey, error) {
+	switch item.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(item.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkInt(item.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch item.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrorInvalidJwk
+		}
+		x, err := decodeJwkInt(item.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt(item.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if item.Crv != "Ed25519" {
+			return nil, ErrorInvalidJwk
+		}
+		x, err := base64.RawURLEncoding.DecodeString(item.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrorInvalidJwk
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrorInvalidJwk
+	}
+}
+
+func decodeJwkInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return nil, ErrorInvalidJwk
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
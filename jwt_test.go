@@ -0,0 +1,70 @@
+package sense
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/sense/config"
+)
+
+func TestJwksFileReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	first, second := createTestJwtSigner(t, "k1"), createTestJwtSigner(t, "k2")
+	cfg := config.Jwt{Jwks: path}
+	claims := map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+	writeTestJwks(t, path, time.Unix(1700000000, 0), first)
+	if _, err := verifyJwt(first.sign(claims), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyJwt(second.sign(claims), cfg); err == nil {
+		t.Fatal("expected unknown key to be rejected")
+	}
+	writeTestJwks(t, path, time.Unix(1700000060, 0), second)
+	if _, err := verifyJwt(second.sign(claims), cfg); err != nil {
+		t.Fatalf("expected rotated key to be loaded: %v", err)
+	}
+	if _, err := verifyJwt(first.sign(claims), cfg); err == nil {
+		t.Fatal("expected removed key to be rejected")
+	}
+}
+
+func createTestJwtSigner(t *testing.T, kid string) *testOidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testOidcProvider{kid: kid, key: key}
+}
+
+func writeTestJwks(t *testing.T, path string, modTime time.Time, signers ...*testOidcProvider) {
+	set := jwks{Keys: make([]jwk, 0, len(signers))}
+	for _, signer := range signers {
+		set.Keys = append(
+			set.Keys, jwk{
+				Kty: "RSA",
+				Kid: signer.kid,
+				Alg: "RS256",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(signer.key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signer.key.E)).Bytes()),
+			},
+		)
+	}
+	bytes, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, bytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,288 @@
+package sense
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type LoginContext interface {
+	In(email, password string) (auth.In, error)
+	Status(email string) (LoginStatus, error)
+	IpStatus(ip string) (LoginStatus, error)
+	Unlock(email string) error
+	UnlockIp(ip string) error
+}
+
+type LoginStatus struct {
+	Failures int       `json:"failures"`
+	Lockouts int       `json:"lockouts"`
+	Locked   bool      `json:"locked"`
+	Until    time.Time `json:"until"`
+}
+
+type login struct {
+	ctx *handlerContext
+}
+
+type loginKeys struct {
+	attempts string
+	lockouts string
+	lock     string
+}
+
+const (
+	loginAccountScope = "account"
+	loginIpScope      = "ip"
+	loginCacheKey     = "login"
+)
+
+const (
+	loginMaxAttempts   = 5
+	loginMaxIpAttempts = 50
+	loginWindow        = 15 * time.Minute
+	loginLockout       = 15 * time.Minute
+	loginMaxLockout    = 24 * time.Hour
+	loginDelay         = 250 * time.Millisecond
+	loginMaxDelay      = 5 * time.Second
+)
+
+func (l *login) In(email, password string) (auth.In, error) {
+	cfg := getLoginConfig(l.ctx.config.Security.Login)
+	if !cfg.Enabled {
+		return l.authenticate(email, password)
+	}
+	cache := l.ctx.atomic
+	account := createLoginKeys(loginAccountScope, email)
+	ip := createLoginKeys(loginIpScope, l.ctx.request.Ip())
+	now := time.Now()
+	until, err := getLoginLock(cache, now, account, ip)
+	if err != nil {
+		return auth.In{}, err
+	}
+	if until.After(now) {
+		l.ctx.send.Header().Set(header.RetryAfter, strconv.Itoa(ceilSeconds(until.Sub(now))))
+		l.report(cfg, config.LoginEventBlocked, email, 0, until)
+		return auth.In{}, ErrorLoginLocked
+	}
+	accountAttempts, err := incrementAtomic(cache, account.attempts, cfg.Window)
+	if err != nil {
+		return auth.In{}, err
+	}
+	ipAttempts, err := incrementAtomic(cache, ip.attempts, cfg.Window)
+	if err != nil {
+		return auth.In{}, err
+	}
+	if accountAttempts > cfg.MaxAttempts || ipAttempts > cfg.MaxIpAttempts {
+		l.report(cfg, config.LoginEventBlocked, email, accountAttempts-1, time.Time{})
+		return auth.In{}, ErrorLoginLocked
+	}
+	if delay := getLoginDelay(cfg, max(accountAttempts, ipAttempts)-1); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-l.ctx.req.Context().Done():
+			timer.Stop()
+			return auth.In{}, l.ctx.req.Context().Err()
+		}
+	}
+	result, inErr := l.authenticate(email, password)
+	if result.Ok && result.Tfa {
+		l.report(cfg, config.LoginEventTfaPending, email, accountAttempts-1, time.Time{})
+		return result, inErr
+	}
+	if result.Ok {
+		return result, l.complete(cfg, email)
+	}
+	l.report(cfg, config.LoginEventFailure, email, accountAttempts, time.Time{})
+	if accountAttempts >= cfg.MaxAttempts {
+		if until, err = lockLogin(cache, account, cfg); err != nil {
+			return result, err
+		}
+		l.report(cfg, config.LoginEventLocked, email, accountAttempts, until)
+	}
+	if ipAttempts >= cfg.MaxIpAttempts {
+		if until, err = lockLogin(cache, ip, cfg); err != nil {
+			return result, err
+		}
+		l.report(cfg, config.LoginEventLocked, email, accountAttempts, until)
+	}
+	if inErr == nil {
+		inErr = auth.ErrorInvalidCredentials
+	}
+	return result, inErr
+}
+
+func (l *login) Status(email string) (LoginStatus, error) {
+	return l.getStatus(createLoginKeys(loginAccountScope, email))
+}
+
+func (l *login) IpStatus(ip string) (LoginStatus, error) {
+	return l.getStatus(createLoginKeys(loginIpScope, ip))
+}
+
+func (l *login) Unlock(email string) error {
+	keys := createLoginKeys(loginAccountScope, email)
+	if err := deleteAtomic(l.ctx.atomic, keys.attempts, keys.lockouts, keys.lock); err != nil {
+		return err
+	}
+	l.report(getLoginConfig(l.ctx.config.Security.Login), config.LoginEventUnlocked, email, 0, time.Time{})
+	return nil
+}
+
+func (l *login) UnlockIp(ip string) error {
+	keys := createLoginKeys(loginIpScope, ip)
+	return deleteAtomic(l.ctx.atomic, keys.attempts, keys.lockouts, keys.lock)
+}
+
+func (l *login) complete(cfg config.Login, email string) error {
+	account := createLoginKeys(loginAccountScope, email)
+	ip := createLoginKeys(loginIpScope, l.ctx.request.Ip())
+	if err := deleteAtomic(l.ctx.atomic, account.attempts, account.lockouts, ip.attempts); err != nil {
+		return err
+	}
+	l.report(cfg, config.LoginEventSuccess, email, 0, time.Time{})
+	return nil
+}
+
+func (l *login) authenticate(email, password string) (auth.In, error) {
+	result, err := l.ctx.Auth().In(email, password)
+	if err != nil || !result.Ok || !result.Tfa {
+		return result, err
+	}
+	return l.ctx.tfa.resume(result)
+}
+
+func (l *login) getStatus(keys loginKeys) (LoginStatus, error) {
+	cache := l.ctx.atomic
+	failures, _, err := getAtomic(cache, keys.attempts)
+	if err != nil {
+		return LoginStatus{}, err
+	}
+	lockouts, _, err := getAtomic(cache, keys.lockouts)
+	if err != nil {
+		return LoginStatus{}, err
+	}
+	now := time.Now()
+	until, err := getLoginLock(cache, now, keys)
+	if err != nil {
+		return LoginStatus{}, err
+	}
+	status := LoginStatus{
+		Failures: int(failures),
+		Lockouts: int(lockouts),
+		Locked:   until.After(now),
+	}
+	if status.Locked {
+		status.Until = until
+	}
+	return status, nil
+}
+
+func (l *login) report(cfg config.Login, eventType, email string, failures int, until time.Time) {
+	details := map[string]any{"failures": failures}
+	if !until.IsZero() {
+		details["until"] = until
+	}
+	_ = writeAudit(l.ctx, createAuditEntry(l.ctx, eventType, email, details))
+	if cfg.Reporter == nil {
+		return
+	}
+	cfg.Reporter(
+		config.LoginEvent{
+			Type:      eventType,
+			Email:     email,
+			Ip:        l.ctx.request.Ip(),
+			UserAgent: l.ctx.request.UserAgent(),
+			Failures:  failures,
+			Until:     until,
+			Time:      time.Now(),
+		},
+	)
+}
+
+func getLoginLock(cache atomicCache, now time.Time, keys ...loginKeys) (time.Time, error) {
+	var result time.Time
+	for _, k := range keys {
+		until, ok, err := getAtomic(cache, k.lock)
+		if err != nil {
+			return result, err
+		}
+		if ok && time.Unix(0, until).After(result) {
+			result = time.Unix(0, until)
+		}
+	}
+	if result.After(now) {
+		return result, nil
+	}
+	return time.Time{}, nil
+}
+
+func lockLogin(cache atomicCache, keys loginKeys, cfg config.Login) (time.Time, error) {
+	lockouts, err := incrementAtomic(cache, keys.lockouts, cfg.MaxLockout+cfg.Window)
+	if err != nil {
+		return time.Time{}, err
+	}
+	lockout := min(cfg.Lockout<<min(lockouts-1, 16), cfg.MaxLockout)
+	if lockout <= 0 {
+		lockout = cfg.MaxLockout
+	}
+	until := time.Now().Add(lockout)
+	if err := setAtomic(cache, keys.lock, until.UnixNano(), lockout); err != nil {
+		return time.Time{}, err
+	}
+	return until, deleteAtomic(cache, keys.attempts)
+}
+
+func getLoginDelay(cfg config.Login, failures int) time.Duration {
+	if failures == 0 || cfg.Delay <= 0 {
+		return 0
+	}
+	delay := cfg.Delay << min(failures-1, 16)
+	if delay <= 0 || delay > cfg.MaxDelay {
+		return cfg.MaxDelay
+	}
+	return delay
+}
+
+func getLoginConfig(cfg config.Login) config.Login {
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = loginMaxAttempts
+	}
+	if cfg.MaxIpAttempts == 0 {
+		cfg.MaxIpAttempts = loginMaxIpAttempts
+	}
+	if cfg.Window == 0 {
+		cfg.Window = loginWindow
+	}
+	if cfg.Lockout == 0 {
+		cfg.Lockout = loginLockout
+	}
+	if cfg.MaxLockout == 0 {
+		cfg.MaxLockout = loginMaxLockout
+	}
+	if cfg.Delay == 0 {
+		cfg.Delay = loginDelay
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = loginMaxDelay
+	}
+	return cfg
+}
+
+func createLoginKeys(scope, value string) loginKeys {
+	hash := sha256.Sum256([]byte(scope + " " + strings.ToLower(strings.TrimSpace(value))))
+	key := loginCacheKey + "-" + hex.EncodeToString(hash[:])
+	return loginKeys{
+		attempts: key,
+		lockouts: key + "-lockouts",
+		lock:     key + "-lock",
+	}
+}
@@ -0,0 +1,233 @@
+package sense
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/quirk"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type testLoginEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func TestLoginDelay(t *testing.T) {
+	cfg := getLoginConfig(config.Login{Enabled: true})
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{0, 0},
+		{1, 250 * time.Millisecond},
+		{2, 500 * time.Millisecond},
+		{3, time.Second},
+		{5, 4 * time.Second},
+		{6, 5 * time.Second},
+		{100, 5 * time.Second},
+	}
+	for _, test := range tests {
+		if delay := getLoginDelay(cfg, test.failures); delay != test.delay {
+			t.Fatalf("%d failures: expected %s, got %s", test.failures, test.delay, delay)
+		}
+	}
+}
+
+func TestLoginLockoutBackoff(t *testing.T) {
+	cfg := getLoginConfig(config.Login{Enabled: true})
+	cache := createMemoryAtomicCache()
+	keys := createLoginKeys(loginAccountScope, "user@example.com")
+	tests := []time.Duration{
+		15 * time.Minute,
+		30 * time.Minute,
+		time.Hour,
+		2 * time.Hour,
+		4 * time.Hour,
+		8 * time.Hour,
+		16 * time.Hour,
+		24 * time.Hour,
+		24 * time.Hour,
+	}
+	for i, lockout := range tests {
+		now := time.Now()
+		until, err := lockLogin(cache, keys, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := until.Sub(now); d < lockout || d > lockout+time.Second {
+			t.Fatalf("lockout %d: expected %s, got %s", i+1, lockout, d)
+		}
+		locked, err := getLoginLock(cache, now, keys)
+		if err != nil || !locked.Equal(until) {
+			t.Fatalf("lockout %d: expected lock until %s, got %s %v", i+1, until, locked, err)
+		}
+	}
+}
+
+func TestLoginBlocksLockedAccountUntilUnlocked(t *testing.T) {
+	events := &testLoginEvents{}
+	app := createTestLoginApp(nil, events)
+	keys := createLoginKeys(loginAccountScope, testTfaEmail)
+	if _, err := lockLogin(app.(*sense).atomic, keys, getLoginConfig(config.Login{Enabled: true})); err != nil {
+		t.Fatal(err)
+	}
+	res := sendTestLoginRequest(app, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}, nil)
+	if !strings.Contains(res.Body.String(), ErrorLoginLocked.Error()) || len(res.Header().Get(header.RetryAfter)) == 0 {
+		t.Fatalf("expected locked login with retry after, got %d %s", res.Code, res.Body.String())
+	}
+	if res := sendTestLoginRequest(app, "/unlock", url.Values{"email": {testTfaEmail}}, nil); !strings.Contains(res.Body.String(), "false") {
+		t.Fatalf("expected account to be unlocked, got %s", res.Body.String())
+	}
+	events.expect(t, config.LoginEventBlocked, config.LoginEventUnlocked)
+}
+
+func TestLoginLockoutAndReset(t *testing.T) {
+	db := createTestTfaDatabase(t)
+	events := &testLoginEvents{}
+	app := createTestLoginApp(db, events)
+	wrong := url.Values{"email": {testTfaEmail}, "password": {"wrong"}}
+	valid := url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}
+	sendTestLoginRequest(app, "/login", wrong, nil)
+	if res := sendTestLoginRequest(app, "/login", valid, nil); !strings.Contains(res.Body.String(), "session") {
+		t.Fatalf("expected session, got %s", res.Body.String())
+	}
+	if failures, err := getTestLoginFailures(app); err != nil || failures != 0 {
+		t.Fatalf("expected counters to be reset, got %d %v", failures, err)
+	}
+	sendTestLoginRequest(app, "/login", wrong, nil)
+	sendTestLoginRequest(app, "/login", wrong, nil)
+	if res := sendTestLoginRequest(app, "/login", valid, nil); !strings.Contains(res.Body.String(), ErrorLoginLocked.Error()) {
+		t.Fatalf("expected locked login, got %s", res.Body.String())
+	}
+	events.expect(
+		t,
+		config.LoginEventFailure, config.LoginEventSuccess,
+		config.LoginEventFailure, config.LoginEventFailure, config.LoginEventLocked, config.LoginEventBlocked,
+	)
+}
+
+func TestLoginResetsAfterTfaVerification(t *testing.T) {
+	db := createTestTfaDatabase(t)
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: testTfaEmail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = auth.CreateUserManager(db, nil, 1, testTfaEmail).Update(
+		auth.User{Tfa: true, TfaSecret: sql.Null[string]{V: key.Secret(), Valid: true}},
+		auth.UserTfa, auth.UserTfaSecret,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := &testLoginEvents{}
+	app := createTestLoginApp(db, events)
+	sendTestLoginRequest(app, "/login", url.Values{"email": {testTfaEmail}, "password": {"wrong"}}, nil)
+	res := sendTestLoginRequest(app, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}, nil)
+	if !strings.Contains(res.Body.String(), "tfa") {
+		t.Fatalf("expected pending tfa, got %s", res.Body.String())
+	}
+	if failures, err := getTestLoginFailures(app); err != nil || failures != 2 {
+		t.Fatalf("expected counters to be kept while tfa is pending, got %d %v", failures, err)
+	}
+	otp, err := totp.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := sendTestLoginRequest(app, "/tfa/verify", url.Values{"code": {otp}}, res.Result().Cookies()); !strings.Contains(res.Body.String(), "ok") {
+		t.Fatalf("expected tfa verification, got %s", res.Body.String())
+	}
+	if failures, err := getTestLoginFailures(app); err != nil || failures != 0 {
+		t.Fatalf("expected counters to be reset, got %d %v", failures, err)
+	}
+	events.expect(t, config.LoginEventFailure, config.LoginEventTfaPending, config.LoginEventSuccess)
+}
+
+func createTestLoginApp(db *quirk.DB, events *testLoginEvents) Sense {
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	if db != nil {
+		cfg.Database = map[string]*quirk.DB{Main: db}
+	}
+	cfg.Security.Tfa.Secret = "tfa-secret"
+	cfg.Security.Login = config.Login{
+		Enabled:     true,
+		MaxAttempts: 2,
+		Delay:       time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		Reporter:    events.add,
+	}
+	app := New(cfg)
+	app.Post(
+		"/login", func(c Context) error {
+			result, err := c.Login().In(c.Request().Raw().FormValue("email"), c.Request().Raw().FormValue("password"))
+			if err != nil {
+				return c.Send().Text(err.Error())
+			}
+			if result.Tfa {
+				return c.Send().Text("tfa")
+			}
+			return c.Send().Text("session")
+		},
+	)
+	app.Post(
+		"/unlock", func(c Context) error {
+			if err := c.Login().Unlock(c.Request().Raw().FormValue("email")); err != nil {
+				return err
+			}
+			status, err := c.Login().Status(c.Request().Raw().FormValue("email"))
+			if err != nil {
+				return err
+			}
+			return c.Send().Bool(status.Locked)
+		},
+	)
+	app.Post(
+		"/tfa/verify", func(c Context) error {
+			return sendTestTfaResult(c, c.Tfa().Verify(c.Request().Raw().FormValue("code"), false))
+		},
+	)
+	return app
+}
+
+func getTestLoginFailures(app Sense) (int64, error) {
+	failures, _, err := getAtomic(app.(*sense).atomic, createLoginKeys(loginAccountScope, testTfaEmail).attempts)
+	return failures, err
+}
+
+func sendTestLoginRequest(app Sense, path string, values url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	req.Header.Set(header.ContentType, contentType.Form)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, req)
+	return res
+}
+
+func (e *testLoginEvents) add(event config.LoginEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event.Type)
+}
+
+func (e *testLoginEvents) expect(t *testing.T, events ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !slices.Equal(e.events, events) {
+		t.Fatalf("expected events %v, got %v", events, e.events)
+	}
+}
@@ -1,10 +1,8 @@
 package sense
 
 import (
-	"errors"
 	"net/http"
 	"slices"
-	"strings"
 
 	"github.com/creamsensation/sense/config"
 )
@@ -14,9 +12,15 @@ func authMiddleware(firewalls []config.Firewall) Handler {
 		if len(firewalls) == 0 {
 			return c.Continue()
 		}
-		session, err := c.Auth().Session().Get()
-		if err != nil || session.Id == 0 {
-			return c.Send().Status(http.StatusForbidden).Error(errors.New(http.StatusText(http.StatusForbidden)))
+		session, err := c.Principal()
+		if err != nil {
+			return c.Send().Status(http.StatusInternalServerError).Error(err)
+		}
+		if session.Pending {
+			return c.Send().Status(http.StatusForbidden).Error(createTfaError(c, ErrorTfaRequired, c.Config().Security.Tfa.Messages.Required))
+		}
+		if !session.Authenticated() {
+			return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
 		}
 		allowed := session.Super
 		if !session.Super {
@@ -35,9 +39,9 @@ func authMiddleware(firewalls []config.Firewall) Handler {
 			}
 		}
 		if !allowed {
-			return c.Send().Status(http.StatusForbidden).Error(errors.New(http.StatusText(http.StatusForbidden)))
+			return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
 		}
-		if allowed {
+		if allowed && session.Kind == config.AuthenticationSession {
 			if err := c.Auth().Session().Renew(); err != nil {
 				return c.Send().Status(http.StatusInternalServerError).Error(err)
 			}
@@ -45,13 +49,3 @@ func authMiddleware(firewalls []config.Firewall) Handler {
 		return c.Continue()
 	}
 }
-
-func trailingSlashMiddleware() Handler {
-	return func(c Context) error {
-		path := c.Request().Path()
-		if strings.HasSuffix(path, "/") {
-			return c.Continue()
-		}
-		return c.Send().Redirect(path + "/")
-	}
-}
@@ -1,9 +1,9 @@
 package sense
 
 import (
+	"net"
 	"net/http"
-	
-	"github.com/creamsensation/socketer"
+	"regexp"
 )
 
 type Assert interface {
@@ -13,23 +13,42 @@ type Assert interface {
 type routerArgs struct {
 	config      Config
 	mux         *http.ServeMux
-	routes      *[]Route
+	routes      *[]*Route
+	host        string
+	hosts       map[string]*hostDispatcher
+	handled     map[string]bool
+	preflights  map[string]*preflight
+	cors        *cors
+	csrfExempt  []*regexp.Regexp
+	proxies     []*net.IPNet
+	atomic      atomicCache
 	pathPrefix  string
 	middlewares []Handler
+	constraints []RouteConstraint
 }
 
 type handlerFuncArgs struct {
 	config      Config
-	route       Route
+	route       *Route
+	routes      *[]*Route
 	handler     Handler
 	middlewares []Handler
-	ws          map[string]socketer.Ws
+	ws          map[string]*wsHub
 	name        string
+	wsOptions   WsOptions
+	cors        *cors
+	proxies     []*net.IPNet
+	atomic      atomicCache
 }
 
 type handlerContextArgs struct {
-	config Config
-	req    *http.Request
-	res    http.ResponseWriter
-	ws     map[string]socketer.Ws
+	config  Config
+	req     *http.Request
+	res     http.ResponseWriter
+	route   *Route
+	routes  *[]*Route
+	ws      map[string]*wsHub
+	conn    *wsConn
+	proxies []*net.IPNet
+	atomic  atomicCache
 }
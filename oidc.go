@@ -0,0 +1,345 @@
+package sense
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type OidcOptions struct {
+	Provision func(c Context, provider string, claims map[string]any) (auth.User, error)
+	Redirect  string
+	Client    *http.Client
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type oidcState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+type oidcJwksEntry struct {
+	mu      sync.Mutex
+	keys    []jwtKey
+	fetched time.Time
+}
+
+type oidcTokenResponse struct {
+	IdToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+}
+
+const (
+	OidcCookieKey     = "X-Oidc"
+	OidcLoginRoute    = "oidc.login"
+	OidcCallbackRoute = "oidc.callback"
+)
+
+const (
+	oidcDuration        = 10 * time.Minute
+	oidcLeeway          = time.Minute
+	oidcRandomBytes     = 32
+	oidcDiscoveryPath   = "/.well-known/openid-configuration"
+	oidcProviderParam   = "provider"
+	oidcLoginPath       = "/login/{provider}"
+	oidcCallbackPath    = "/login/{provider}/callback"
+	oidcDefaultRedirect = "/"
+	oidcJwksRefresh     = time.Minute
+)
+
+var (
+	oidcDefaultScopes = []string{"openid", "email", "profile"}
+	oidcDiscoveries   = &sync.Map{}
+	oidcJwks          = &sync.Map{}
+	oidcClient        = &http.Client{Timeout: 10 * time.Second}
+)
+
+func createOidcLoginHandler(options OidcOptions) Handler {
+	return func(c Context) error {
+		name := c.Request().Raw().PathValue(oidcProviderParam)
+		provider, ok := c.Config().Security.Oidc[name]
+		if !ok {
+			return c.Send().Status(http.StatusNotFound).Error(ErrorInvalidProvider)
+		}
+		discovery, err := getOidcDiscovery(getOidcClient(options), provider.Issuer)
+		if err != nil {
+			return c.Send().Status(http.StatusBadGateway).Error(err)
+		}
+		state := oidcState{
+			Provider: name,
+			State:    createOidcRandom(),
+			Nonce:    createOidcRandom(),
+			Verifier: createOidcRandom(),
+			Redirect: getOidcRedirect(c.Request().Raw().URL.Query().Get("redirect"), options),
+		}
+		stateBytes, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		c.Cookie().Set(OidcCookieKey, base64.RawURLEncoding.EncodeToString(stateBytes), oidcDuration)
+		challenge := sha256.Sum256([]byte(state.Verifier))
+		scopes := provider.Scopes
+		if len(scopes) == 0 {
+			scopes = oidcDefaultScopes
+		}
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {provider.ClientId},
+			"redirect_uri":          {getOidcRedirectUrl(c, name, provider)},
+			"scope":                 {strings.Join(scopes, " ")},
+			"state":                 {state.State},
+			"nonce":                 {state.Nonce},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+		separator := "?"
+		if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+			separator = "&"
+		}
+		return c.Send().Redirect(discovery.AuthorizationEndpoint + separator + query.Encode())
+	}
+}
+
+func createOidcCallbackHandler(options OidcOptions) Handler {
+	return func(c Context) error {
+		name := c.Request().Raw().PathValue(oidcProviderParam)
+		provider, ok := c.Config().Security.Oidc[name]
+		if !ok {
+			return c.Send().Status(http.StatusNotFound).Error(ErrorInvalidProvider)
+		}
+		state, err := readOidcState(c.Cookie().Get(OidcCookieKey))
+		c.Cookie().Destroy(OidcCookieKey)
+		query := c.Request().Raw().URL.Query()
+		if err != nil || state.Provider != name || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+			return c.Send().Status(http.StatusUnauthorized).Error(ErrorInvalidOidc)
+		}
+		if e := query.Get("error"); len(e) > 0 {
+			return c.Send().Status(http.StatusUnauthorized).Error(fmt.Errorf("%w: %s", ErrorInvalidOidc, e))
+		}
+		client := getOidcClient(options)
+		discovery, err := getOidcDiscovery(client, provider.Issuer)
+		if err != nil {
+			return c.Send().Status(http.StatusBadGateway).Error(err)
+		}
+		idToken, err := exchangeOidcCode(client, discovery, provider, query.Get("code"), getOidcRedirectUrl(c, name, provider), state.Verifier)
+		if err != nil {
+			return c.Send().Status(http.StatusUnauthorized).Error(err)
+		}
+		claims, err := verifyOidcIdToken(client, discovery, provider, idToken)
+		if err != nil || claims["nonce"] != state.Nonce {
+			return c.Send().Status(http.StatusUnauthorized).Error(ErrorInvalidOidc)
+		}
+		if options.Provision == nil {
+			return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+		}
+		user, err := options.Provision(c, name, claims)
+		if err != nil {
+			return err
+		}
+		if user.Id == 0 {
+			return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+		}
+		if err := c.Tfa().Login(user); err != nil {
+			return err
+		}
+		return c.Send().Redirect(state.Redirect)
+	}
+}
+
+func getOidcDiscovery(client *http.Client, issuer string) (oidcDiscovery, error) {
+	if discovery, ok := oidcDiscoveries.Load(issuer); ok {
+		return discovery.(oidcDiscovery), nil
+	}
+	var discovery oidcDiscovery
+	if err := getOidcJson(client, strings.TrimSuffix(issuer, "/")+oidcDiscoveryPath, &discovery); err != nil {
+		return discovery, err
+	}
+	if discovery.Issuer != issuer {
+		return discovery, fmt.Errorf("%w: issuer mismatch", ErrorInvalidOidc)
+	}
+	oidcDiscoveries.Store(issuer, discovery)
+	return discovery, nil
+}
+
+func exchangeOidcCode(client *http.Client, discovery oidcDiscovery, provider config.OidcProvider, code, redirectUrl, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectUrl},
+		"client_id":     {provider.ClientId},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(header.ContentType, contentType.Form)
+	req.Header.Set(header.Accept, contentType.Json)
+	if len(provider.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientId), url.QueryEscape(provider.ClientSecret))
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	var token oidcTokenResponse
+	if err := readOidcResponse(res, &token); err != nil {
+		return "", err
+	}
+	if len(token.IdToken) == 0 {
+		return "", fmt.Errorf("%w: missing id token", ErrorInvalidOidc)
+	}
+	return token.IdToken, nil
+}
+
+func verifyOidcIdToken(client *http.Client, discovery oidcDiscovery, provider config.OidcProvider, idToken string) (map[string]any, error) {
+	var h jwtHeader
+	if err := decodeJwtPart(strings.Split(idToken, ".")[0], &h); err != nil {
+		return nil, err
+	}
+	keys, err := loadOidcJwks(client, discovery.JwksUri, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := verifyJwtWithKeys(idToken, keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateJwtClaims(claims, config.Jwt{Issuer: discovery.Issuer, Audience: provider.ClientId, Leeway: oidcLeeway}, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func loadOidcJwks(client *http.Client, jwksUri string, kid string) ([]jwtKey, error) {
+	value, _ := oidcJwks.LoadOrStore(jwksUri, &oidcJwksEntry{})
+	entry := value.(*oidcJwksEntry)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.fetched.IsZero() && (containsJwtKid(entry.keys, kid) || time.Since(entry.fetched) < oidcJwksRefresh) {
+		return entry.keys, nil
+	}
+	res, err := client.Get(jwksUri)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidOidc, res.Status)
+	}
+	bytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Join(ErrorReadData, err)
+	}
+	keys, err := parseJwks(bytes)
+	if err != nil {
+		return nil, err
+	}
+	entry.keys = keys
+	entry.fetched = time.Now()
+	return keys, nil
+}
+
+func containsJwtKid(keys []jwtKey, kid string) bool {
+	return slices.ContainsFunc(
+		keys, func(key jwtKey) bool {
+			return len(kid) == 0 || key.kid == kid
+		},
+	)
+}
+
+func getOidcJson(client *http.Client, u string, target any) error {
+	res, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	return readOidcResponse(res, target)
+}
+
+func readOidcResponse(res *http.Response, target any) error {
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrorInvalidOidc, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return errors.Join(ErrorReadData, err)
+	}
+	return nil
+}
+
+func readOidcState(value string) (oidcState, error) {
+	var state oidcState
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return state, ErrorInvalidOidc
+	}
+	if err := json.Unmarshal(bytes, &state); err != nil {
+		return state, ErrorInvalidOidc
+	}
+	return state, nil
+}
+
+func getOidcRedirectUrl(c Context, name string, provider config.OidcProvider) string {
+	if len(provider.RedirectUrl) > 0 {
+		return provider.RedirectUrl
+	}
+	return c.AbsoluteUrl(OidcCallbackRoute, map[string]any{oidcProviderParam: name})
+}
+
+func getOidcRedirect(redirect string, options OidcOptions) string {
+	if strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") && !strings.HasPrefix(redirect, "/\\") {
+		return redirect
+	}
+	if len(options.Redirect) > 0 {
+		return options.Redirect
+	}
+	return oidcDefaultRedirect
+}
+
+func getOidcClient(options OidcOptions) *http.Client {
+	if options.Client != nil {
+		return options.Client
+	}
+	return oidcClient
+}
+
+func createOidcRandom() string {
+	bytes := make([]byte, oidcRandomBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,264 @@
+package sense
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type testOidcProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	mu        sync.Mutex
+	codes     map[string]url.Values
+	nonce     string
+	kid       string
+	jwksFetch atomic.Int32
+}
+
+type testOidcApp struct {
+	sense    Sense
+	provider *testOidcProvider
+	claims   map[string]any
+}
+
+const (
+	testOidcClientId    = "client"
+	testOidcRedirectUrl = "http://app.test/auth/login/test/callback"
+)
+
+func TestOidcLoginFlow(t *testing.T) {
+	app := createTestOidcApp(t)
+	location, cookies := app.login(t)
+	if location.Query().Get("code_challenge_method") != "S256" || len(location.Query().Get("code_challenge")) == 0 {
+		t.Fatalf("missing pkce challenge: %s", location)
+	}
+	if len(location.Query().Get("state")) == 0 || len(location.Query().Get("nonce")) == 0 {
+		t.Fatalf("missing state or nonce: %s", location)
+	}
+	code := app.provider.authorize(location.Query())
+	res := app.callback(code, location.Query().Get("state"), cookies)
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("expected provision to be reached, got %d %s", res.Code, res.Body.String())
+	}
+	if app.claims["sub"] != "user-1" || app.claims["nonce"] != location.Query().Get("nonce") {
+		t.Fatalf("unexpected claims: %v", app.claims)
+	}
+}
+
+func TestOidcRejectsInvalidState(t *testing.T) {
+	app := createTestOidcApp(t)
+	location, cookies := app.login(t)
+	code := app.provider.authorize(location.Query())
+	if res := app.callback(code, "invalid", cookies); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if res := app.callback(code, location.Query().Get("state"), nil); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d without state cookie, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if app.claims != nil {
+		t.Fatal("provision must not be reached")
+	}
+}
+
+func TestOidcRejectsInvalidVerifier(t *testing.T) {
+	app := createTestOidcApp(t)
+	first, _ := app.login(t)
+	second, cookies := app.login(t)
+	code := app.provider.authorize(first.Query())
+	if res := app.callback(code, second.Query().Get("state"), cookies); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if app.claims != nil {
+		t.Fatal("provision must not be reached")
+	}
+}
+
+func TestOidcRejectsInvalidNonce(t *testing.T) {
+	app := createTestOidcApp(t)
+	location, cookies := app.login(t)
+	app.provider.nonce = "invalid"
+	code := app.provider.authorize(location.Query())
+	if res := app.callback(code, location.Query().Get("state"), cookies); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if app.claims != nil {
+		t.Fatal("provision must not be reached")
+	}
+}
+
+func TestOidcRefreshesJwksOnlyForUnknownKid(t *testing.T) {
+	app := createTestOidcApp(t)
+	for i := 0; i < 2; i++ {
+		location, cookies := app.login(t)
+		code := app.provider.authorize(location.Query())
+		if res := app.callback(code, location.Query().Get("state"), cookies); res.Code != http.StatusForbidden {
+			t.Fatalf("expected %d, got %d", http.StatusForbidden, res.Code)
+		}
+	}
+	if count := app.provider.jwksFetch.Load(); count != 1 {
+		t.Fatalf("expected 1 jwks fetch for a known kid, got %d", count)
+	}
+	app.claims = nil
+	app.provider.kid = "rotated"
+	location, cookies := app.login(t)
+	code := app.provider.authorize(location.Query())
+	if res := app.callback(code, location.Query().Get("state"), cookies); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if count := app.provider.jwksFetch.Load(); count != 1 {
+		t.Fatalf("expected refresh to be rate limited, got %d fetches", count)
+	}
+}
+
+func createTestOidcApp(t *testing.T) *testOidcApp {
+	provider := createTestOidcProvider(t)
+	app := &testOidcApp{provider: provider}
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Security.Oidc = map[string]config.OidcProvider{
+		"test": {Issuer: provider.server.URL, ClientId: testOidcClientId, RedirectUrl: testOidcRedirectUrl},
+	}
+	app.sense = New(cfg)
+	app.sense.Group("/auth").Oidc(
+		OidcOptions{
+			Client: provider.server.Client(),
+			Provision: func(c Context, name string, claims map[string]any) (auth.User, error) {
+				app.claims = claims
+				return auth.User{}, nil
+			},
+		},
+	)
+	return app
+}
+
+func createTestOidcProvider(t *testing.T) *testOidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testOidcProvider{key: key, codes: make(map[string]url.Values), kid: "k1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET "+oidcDiscoveryPath, func(res http.ResponseWriter, req *http.Request) {
+			_ = json.NewEncoder(res).Encode(
+				oidcDiscovery{
+					Issuer:                p.server.URL,
+					AuthorizationEndpoint: p.server.URL + "/authorize",
+					TokenEndpoint:         p.server.URL + "/token",
+					JwksUri:               p.server.URL + "/jwks",
+				},
+			)
+		},
+	)
+	mux.HandleFunc(
+		"GET /jwks", func(res http.ResponseWriter, req *http.Request) {
+			p.jwksFetch.Add(1)
+			_ = json.NewEncoder(res).Encode(
+				jwks{
+					Keys: []jwk{
+						{
+							Kty: "RSA",
+							Kid: "k1",
+							Alg: "RS256",
+							Use: "sig",
+							N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+							E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+						},
+					},
+				},
+			)
+		},
+	)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testOidcProvider) authorize(query url.Values) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := createOidcRandom()
+	p.codes[code] = query
+	return code
+}
+
+func (p *testOidcProvider) token(res http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	authorization, ok := p.codes[req.FormValue("code")]
+	delete(p.codes, req.FormValue("code"))
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(req.FormValue("code_verifier")))
+	if !ok ||
+		req.FormValue("client_id") != testOidcClientId ||
+		req.FormValue("redirect_uri") != authorization.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.Get("code_challenge") {
+		http.Error(res, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	nonce := authorization.Get("nonce")
+	if len(p.nonce) > 0 {
+		nonce = p.nonce
+	}
+	_ = json.NewEncoder(res).Encode(
+		oidcTokenResponse{
+			IdToken: p.sign(
+				map[string]any{
+					"iss":   p.server.URL,
+					"aud":   testOidcClientId,
+					"sub":   "user-1",
+					"nonce": nonce,
+					"exp":   time.Now().Add(time.Minute).Unix(),
+				},
+			),
+		},
+	)
+}
+
+func (p *testOidcProvider) sign(claims map[string]any) string {
+	h, _ := json.Marshal(jwtHeader{Alg: "RS256", Kid: p.kid})
+	c, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (a *testOidcApp) login(t *testing.T) (*url.URL, []*http.Cookie) {
+	res := httptest.NewRecorder()
+	a.sense.(*sense).handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/auth/login/test", nil))
+	location, err := url.Parse(res.Header().Get("Location"))
+	if err != nil || location.Path != "/authorize" {
+		t.Fatalf("unexpected login response: %d %s", res.Code, res.Header().Get("Location"))
+	}
+	return location, res.Result().Cookies()
+}
+
+func (a *testOidcApp) callback(code, state string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/login/test/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	a.sense.(*sense).handler.ServeHTTP(res, req)
+	return res
+}
@@ -0,0 +1,461 @@
+package sense
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type OpenapiSchema interface {
+	OpenapiSchema() map[string]any
+}
+
+type openapi struct {
+	config          Config
+	schemas         map[string]any
+	securitySchemes map[string]any
+}
+
+const (
+	OpenapiVersion = "3.1.0"
+)
+
+var (
+	openapiSchemaNameMatcher = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+	openapiSchemaType        = reflect.TypeFor[OpenapiSchema]()
+)
+
+const (
+	openapiSessionSecurity = "session"
+	openapiApiKeySecurity  = "apiKey"
+	openapiBearerSecurity  = "bearer"
+	openapiErrorSchema     = "Error"
+	openapiViewerAsset     = "/viewer.js"
+	openapiViewer          = `<!DOCTYPE html>
+<html>
+<head>
+<title>%s</title>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style nonce="%s">
+body{margin:0 auto;max-width:960px;padding:24px;font-family:system-ui,sans-serif;color:#1f2328}
+h2{margin-top:32px;border-bottom:1px solid #d0d7de;text-transform:capitalize}
+.version{color:#656d76}
+.operation{margin:8px 0;border:1px solid #d0d7de;border-radius:6px;padding:8px 12px}
+.operation summary{cursor:pointer;display:flex;gap:12px;align-items:center}
+.method{min-width:64px;font-weight:600;font-size:13px}
+.get .method{color:#0969da}.post .method{color:#1a7f37}.put .method,.patch .method{color:#9a6700}.delete .method{color:#cf222e}
+.path{font-family:monospace}
+.summary{color:#656d76}
+table{border-collapse:collapse}td{padding:4px 12px 4px 0;font-family:monospace}
+pre{background:#f6f8fa;padding:12px;overflow:auto;border-radius:6px}
+.error{color:#cf222e}
+</style>
+</head>
+<body>
+<div id="openapi" data-spec-url="%s"></div>
+<script nonce="%s" src="%s"></script>
+</body>
+</html>`
+	openapiRedocViewer = `<!DOCTYPE html>
+<html>
+<head>
+<title>%s</title>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="%s"></redoc>
+<script nonce="%s" src="%s"%s></script>
+</body>
+</html>`
+)
+
+var (
+	//go:embed internal/asset/openapi-viewer.js
+	openapiViewerScript []byte
+)
+
+func createOpenapiDocument(config Config, routes []Route, host string) map[string]any {
+	o := &openapi{config: config, schemas: make(map[string]any), securitySchemes: make(map[string]any)}
+	o.schemas[openapiErrorSchema] = map[string]any{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+		},
+	}
+	title := config.Openapi.Title
+	if len(title) == 0 {
+		title = config.App.Name
+	}
+	version := config.Openapi.Version
+	if len(version) == 0 {
+		version = "1.0.0"
+	}
+	paths := make(map[string]any)
+	for _, route := range getOpenapiRoutes(routes, host) {
+		path := createOpenapiPath(route.Path)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = o.createOperation(route)
+	}
+	return map[string]any{
+		"openapi": OpenapiVersion,
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas":         o.schemas,
+			"securitySchemes": o.securitySchemes,
+		},
+	}
+}
+
+func getOpenapiRoutes(routes []Route, host string) []Route {
+	result := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		if route.Method == "WS" || !matchOpenapiHost(route.Host, host) {
+			continue
+		}
+		index := slices.IndexFunc(
+			result, func(item Route) bool {
+				return item.Method == route.Method && createOpenapiPath(item.Path) == createOpenapiPath(route.Path)
+			},
+		)
+		if index == -1 {
+			result = append(result, route)
+			continue
+		}
+		if len(result[index].Host) == 0 {
+			result[index] = route
+		}
+	}
+	return result
+}
+
+func matchOpenapiHost(routeHost, host string) bool {
+	if len(routeHost) == 0 {
+		return true
+	}
+	if len(host) == 0 {
+		return false
+	}
+	matcher, _ := createHostMatcher(routeHost)
+	return matcher.MatchString(host)
+}
+
+func createOpenapiPath(path string) string {
+	path = strings.ReplaceAll(path, "{$}", "")
+	path = strings.ReplaceAll(path, "...}", "}")
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
+
+func (o *openapi) createOperation(route Route) map[string]any {
+	operation := map[string]any{
+		"responses": o.createResponses(route),
+	}
+	if len(route.Name) > 0 {
+		operation["operationId"] = route.Name
+	}
+	if len(route.Summary) > 0 {
+		operation["summary"] = route.Summary
+	}
+	if len(route.Tags) > 0 {
+		operation["tags"] = route.Tags
+	}
+	if parameters := o.createParameters(route); len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if route.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": o.createValueSchema(route.Request),
+				},
+			},
+		}
+	}
+	if len(route.Permissions) > 0 {
+		operation["x-permissions"] = route.Permissions
+	}
+	if len(route.Firewalls) > 0 || len(route.Permissions) > 0 || len(route.Policies) > 0 {
+		roles := route.Roles
+		if roles == nil {
+			roles = make([]string, 0)
+		}
+		operation["security"] = o.createSecurity(route, roles)
+	}
+	return operation
+}
+
+func (o *openapi) createSecurity(route Route, roles []string) []map[string]any {
+	result := make([]map[string]any, 0)
+	for _, mode := range getFirewallsAuthentication(&route) {
+		switch mode {
+		case config.AuthenticationSession:
+			o.securitySchemes[openapiSessionSecurity] = map[string]any{
+				"type": "apiKey",
+				"in":   "cookie",
+				"name": auth.SessionCookieKey,
+			}
+			result = append(result, map[string]any{openapiSessionSecurity: roles})
+		case config.AuthenticationApiKey:
+			o.securitySchemes[openapiApiKeySecurity] = map[string]any{
+				"type": "apiKey",
+				"in":   "header",
+				"name": getApiKeyHeader(o.config.Security.Authentication),
+			}
+			result = append(result, map[string]any{openapiApiKeySecurity: roles})
+		case config.AuthenticationToken, config.AuthenticationJwt:
+			if !slices.ContainsFunc(
+				result, func(item map[string]any) bool {
+					_, ok := item[openapiBearerSecurity]
+					return ok
+				},
+			) {
+				result = append(result, map[string]any{openapiBearerSecurity: roles})
+			}
+			o.securitySchemes[openapiBearerSecurity] = map[string]any{
+				"type":   "http",
+				"scheme": "bearer",
+			}
+		}
+	}
+	return result
+}
+
+func (o *openapi) createParameters(route Route) []map[string]any {
+	result := make([]map[string]any, 0)
+	path := createOpenapiPath(route.Path)
+	for {
+		start := strings.IndexByte(path, '{')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(path[start:], '}') + start
+		name := path[start+1 : end]
+		path = path[end+1:]
+		schema := map[string]any{"type": "string"}
+		for _, constraint := range route.Constraints {
+			if constraint.Name == name {
+				schema = createConstraintSchema(constraint)
+			}
+		}
+		result = append(
+			result, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   schema,
+			},
+		)
+	}
+	return result
+}
+
+func (o *openapi) createResponses(route Route) map[string]any {
+	result := map[string]any{
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/" + openapiErrorSchema},
+				},
+			},
+		},
+	}
+	if len(route.Responses) == 0 {
+		result[strconv.Itoa(http.StatusOK)] = map[string]any{"description": http.StatusText(http.StatusOK)}
+		return result
+	}
+	for statusCode, value := range route.Responses {
+		response := map[string]any{"description": http.StatusText(statusCode)}
+		if value != nil {
+			response["content"] = map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{
+						"type":     "object",
+						"required": []string{"result"},
+						"properties": map[string]any{
+							"result": o.createValueSchema(value),
+						},
+					},
+				},
+			}
+		}
+		result[strconv.Itoa(statusCode)] = response
+	}
+	return result
+}
+
+func (o *openapi) createValueSchema(value any) map[string]any {
+	switch v := value.(type) {
+	case OpenapiSchema:
+		return v.OpenapiSchema()
+	case map[string]any:
+		return v
+	}
+	return o.createSchema(reflect.TypeOf(value))
+}
+
+func (o *openapi) createSchema(t reflect.Type) map[string]any {
+	if t.Implements(openapiSchemaType) && t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		return reflect.Zero(t).Interface().(OpenapiSchema).OpenapiSchema()
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": o.createSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": o.createSchema(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return o.createStructSchema(t)
+		}
+		name := openapiSchemaNameMatcher.ReplaceAllString(t.String(), "_")
+		if _, ok := o.schemas[name]; !ok {
+			o.schemas[name] = map[string]any{}
+			o.schemas[name] = o.createStructSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (o *openapi) createStructSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		properties[name] = o.createSchema(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"required":   required,
+		"properties": properties,
+	}
+}
+
+func createConstraintSchema(constraint RouteConstraint) map[string]any {
+	switch constraint.Pattern {
+	case ConstraintInt:
+		return map[string]any{"type": "integer"}
+	case ConstraintUint:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case ConstraintFloat:
+		return map[string]any{"type": "number"}
+	case ConstraintBool:
+		return map[string]any{"type": "boolean"}
+	case ConstraintUuid:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case ConstraintDate:
+		return map[string]any{"type": "string", "format": "date"}
+	case ConstraintAlpha, ConstraintAlnum, ConstraintSlug:
+		return map[string]any{"type": "string"}
+	default:
+		return map[string]any{"type": "string", "pattern": "^(?:" + constraint.Pattern + ")$"}
+	}
+}
+
+func createOpenapiHandlerFunc(s Sense) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		bytes, err := json.Marshal(s.Openapi(getRequestHostname(req)))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set(header.ContentType, contentType.Json)
+		if _, err = res.Write(bytes); err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+func createOpenapiViewerHandlerFunc(config Config) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set(header.ContentType, contentType.Html)
+		title := html.EscapeString(config.App.Name)
+		specUrl := html.EscapeString(formatPath(config.Router.Prefix) + formatPath(config.Openapi.Path))
+		nonce := html.EscapeString(getRequestNonce(req))
+		var err error
+		if len(config.Openapi.ViewerScript) > 0 {
+			var integrity string
+			if len(config.Openapi.ViewerIntegrity) > 0 {
+				integrity = ` integrity="` + html.EscapeString(config.Openapi.ViewerIntegrity) + `" crossorigin="anonymous"`
+			}
+			_, err = fmt.Fprintf(
+				res, openapiRedocViewer, title, specUrl, nonce, html.EscapeString(config.Openapi.ViewerScript), integrity,
+			)
+		} else {
+			script := html.EscapeString(formatPath(config.Router.Prefix) + formatPath(config.Openapi.ViewerPath) + openapiViewerAsset)
+			_, err = fmt.Fprintf(res, openapiViewer, title, nonce, specUrl, nonce, script)
+		}
+		if err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
+
+func createOpenapiViewerAssetHandlerFunc() func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set(header.ContentType, contentType.Javascript)
+		if _, err := res.Write(openapiViewerScript); err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
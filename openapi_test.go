@@ -0,0 +1,39 @@
+package sense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+func TestOpenapiViewerServesEmbeddedScriptWithNonce(t *testing.T) {
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Openapi.Path = "/openapi.json"
+	cfg.Openapi.ViewerPath = "/docs"
+	cfg.Security.Headers.Enabled = true
+	app := New(cfg)
+	res := httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	csp := res.Header().Get(header.ContentSecurityPolicy)
+	_, nonce, _ := strings.Cut(csp, "'nonce-")
+	nonce, _, _ = strings.Cut(nonce, "'")
+	if len(nonce) == 0 {
+		t.Fatalf("missing csp nonce: %s", csp)
+	}
+	body := res.Body.String()
+	if !strings.Contains(body, `<script nonce="`+nonce+`" src="/docs/viewer.js">`) {
+		t.Fatalf("viewer script must be same origin with the csp nonce: %s", body)
+	}
+	if strings.Contains(body, "https://") {
+		t.Fatalf("viewer must not load external assets: %s", body)
+	}
+	res = httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/docs/viewer.js", nil))
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "data-spec-url") {
+		t.Fatalf("unexpected viewer script response: %d", res.Code)
+	}
+}
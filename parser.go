@@ -46,9 +46,7 @@ func (p *parser) QueryParam(key string, target any) error {
 	if !p.req.URL.Query().Has(key) {
 		return ErrorQueryParamMissing
 	}
-	stringValue := p.req.URL.Query().Get(key)
-	setValueToReflected(t.Elem().Kind(), v.Elem(), stringValue)
-	return nil
+	return setValueToReflected(v.Elem(), p.req.URL.Query()[key]...)
 }
 
 func (p *parser) MustQueryParam(key string, target any) {
@@ -68,8 +66,7 @@ func (p *parser) PathValue(key string, target any) error {
 	if len(pathValue) == 0 {
 		return ErrorPathValueMissing
 	}
-	setValueToReflected(t.Elem().Kind(), v.Elem(), pathValue)
-	return nil
+	return setValueToReflected(v.Elem(), pathValue)
 }
 
 func (p *parser) MustPathValue(key string, target any) {
@@ -88,13 +85,16 @@ func (p *parser) Url(target any) error {
 	for i := 0; i < t.Elem().NumField(); i++ {
 		queryKey := t.Elem().Field(i).Tag.Get("query")
 		if len(queryKey) > 0 && p.req.URL.Query().Has(queryKey) {
-			queryParam := p.req.URL.Query().Get(queryKey)
-			setValueToReflected(t.Elem().Field(i).Type.Kind(), v.Elem().Field(i), queryParam)
+			if err := setValueToReflected(v.Elem().Field(i), p.req.URL.Query()[queryKey]...); err != nil && !errors.Is(err, ErrorUnsupportedType) {
+				return err
+			}
 		}
 		pathKey := t.Elem().Field(i).Tag.Get("path")
 		pathValue := p.req.PathValue(pathKey)
 		if len(pathKey) > 0 && len(pathValue) > 0 {
-			setValueToReflected(t.Elem().Field(i).Type.Kind(), v.Elem().Field(i), pathValue)
+			if err := setValueToReflected(v.Elem().Field(i), pathValue); err != nil && !errors.Is(err, ErrorUnsupportedType) {
+				return err
+			}
 		}
 	}
 	return nil
@@ -0,0 +1,70 @@
+package sense
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/creamsensation/auth"
+)
+
+type Policy func(c Context) (bool, error)
+
+const (
+	PermissionWildcard = "*"
+)
+
+func permissionMiddleware(route *Route) Handler {
+	return func(c Context) error {
+		principal, err := c.Principal()
+		if err != nil {
+			return c.Send().Status(http.StatusInternalServerError).Error(err)
+		}
+		if !principal.Authenticated() {
+			return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+		}
+		for _, permission := range route.Permissions {
+			if !principalCan(principal, c.Config().Security.Permissions, permission) {
+				return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+			}
+		}
+		for _, policy := range route.Policies {
+			ok, err := policy(c)
+			if err != nil {
+				return c.Send().Status(http.StatusInternalServerError).Error(err)
+			}
+			if !ok {
+				return c.Send().Status(http.StatusForbidden).Error(ErrorForbidden)
+			}
+		}
+		return c.Continue()
+	}
+}
+
+func getSessionPermissions(session auth.Session, permissions map[string][]string) []string {
+	result := make([]string, 0)
+	for _, role := range session.Roles {
+		for _, permission := range permissions[role] {
+			if !slices.Contains(result, permission) {
+				result = append(result, permission)
+			}
+		}
+	}
+	return result
+}
+
+func containsPermission(permissions []string, permission string) bool {
+	return slices.ContainsFunc(
+		permissions, func(granted string) bool {
+			return matchPermission(granted, permission)
+		},
+	)
+}
+
+func matchPermission(granted, permission string) bool {
+	if granted == PermissionWildcard || granted == permission {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(granted, "."+PermissionWildcard)
+	return ok && strings.HasPrefix(permission, prefix+".")
+}
@@ -0,0 +1,197 @@
+package sense
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/creamsensation/auth"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type Principal struct {
+	auth.Session
+	Kind    string         `json:"kind"`
+	Subject string         `json:"subject"`
+	Scopes  []string       `json:"scopes"`
+	Claims  map[string]any `json:"claims"`
+	Pending bool           `json:"pending"`
+}
+
+const (
+	bearerPrefix = "Bearer "
+)
+
+func (p Principal) Authenticated() bool {
+	return !p.Pending && (p.Id > 0 || len(p.Subject) > 0)
+}
+
+func resolvePrincipal(c *handlerContext) (Principal, error) {
+	authentication := c.config.Security.Authentication
+	var pending Principal
+	for _, mode := range getFirewallsAuthentication(c.route) {
+		switch mode {
+		case config.AuthenticationSession:
+			if isSessionActive(c.Cache(), c.cookie.Get(auth.SessionCookieKey)) {
+				session, err := c.Auth().Session().Get()
+				if err == nil && session.Id > 0 {
+					if err := trackSession(c, session); err != nil {
+						return Principal{}, err
+					}
+					return Principal{
+						Session: session,
+						Kind:    mode,
+						Subject: strconv.Itoa(session.Id),
+					}, nil
+				}
+			}
+			if !isTfaPending(c.Cache(), c.cookie.Get(auth.TfaCookieKey)) {
+				continue
+			}
+			if id, err := c.Auth().Tfa().GetPendingUserId(); err == nil && id > 0 {
+				pending = Principal{
+					Session: auth.Session{Id: id},
+					Kind:    mode,
+					Subject: strconv.Itoa(id),
+					Pending: true,
+				}
+			}
+		case config.AuthenticationApiKey:
+			if principal, ok := resolveApiKeyPrincipal(c.req.Header.Get(getApiKeyHeader(authentication)), c.config.Security); ok {
+				return principal, nil
+			}
+		case config.AuthenticationToken:
+			token := getBearerToken(c.req)
+			if len(token) == 0 || isJwt(token) {
+				continue
+			}
+			principal, err := resolveTokenPrincipal(c, token)
+			if err != nil {
+				return Principal{}, err
+			}
+			if principal.Authenticated() {
+				return principal, nil
+			}
+		case config.AuthenticationJwt:
+			token := getBearerToken(c.req)
+			if len(token) == 0 || !isJwt(token) {
+				continue
+			}
+			claims, err := verifyJwt(token, authentication.Jwt)
+			if err == nil {
+				return createJwtPrincipal(claims, c.config.Security), nil
+			}
+		}
+	}
+	return pending, nil
+}
+
+func resolveApiKeyPrincipal(key string, security config.Security) (Principal, bool) {
+	if len(key) == 0 {
+		return Principal{}, false
+	}
+	for _, apiKey := range security.Authentication.ApiKeys {
+		if len(apiKey.Key) == 0 || subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) != 1 {
+			continue
+		}
+		return Principal{
+			Session: auth.Session{
+				Roles: apiKey.Roles,
+				Super: containsSuperRole(security.Auth, apiKey.Roles),
+			},
+			Kind:    config.AuthenticationApiKey,
+			Subject: apiKey.Name,
+			Scopes:  apiKey.Scopes,
+		}, true
+	}
+	return Principal{}, false
+}
+
+func resolveTokenPrincipal(c *handlerContext, value string) (Principal, error) {
+	tokens := c.Tokens(getTokensDatabase(c.config.Security.Authentication))
+	token, err := tokens.Get(value)
+	if err != nil || token.Id == 0 {
+		return Principal{}, err
+	}
+	user, err := c.Auth(getTokensDatabase(c.config.Security.Authentication)).User().Get(token.UserId)
+	if err != nil || !user.Active {
+		return Principal{}, err
+	}
+	return Principal{
+		Session: auth.Session{
+			Id:    user.Id,
+			Email: user.Email,
+			Roles: user.Roles,
+			Super: containsSuperRole(c.config.Security.Auth, user.Roles),
+		},
+		Kind:    config.AuthenticationToken,
+		Subject: strconv.Itoa(user.Id),
+		Scopes:  token.Scopes,
+	}, nil
+}
+
+func principalCan(principal Principal, permissions map[string][]string, permission string) bool {
+	if !principal.Authenticated() {
+		return false
+	}
+	if len(principal.Scopes) > 0 && !containsPermission(principal.Scopes, permission) {
+		return false
+	}
+	return principal.Super || containsPermission(getSessionPermissions(principal.Session, permissions), permission)
+}
+
+func getFirewallsAuthentication(route *Route) []string {
+	result := make([]string, 0)
+	if route != nil {
+		for _, firewall := range route.Firewalls {
+			for _, mode := range firewall.Authentication {
+				if !slices.Contains(result, mode) {
+					result = append(result, mode)
+				}
+			}
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, config.AuthenticationSession)
+	}
+	return result
+}
+
+func getApiKeyHeader(authentication config.Authentication) string {
+	if len(authentication.ApiKeyHeader) > 0 {
+		return authentication.ApiKeyHeader
+	}
+	return header.XApiKey
+}
+
+func getTokensDatabase(authentication config.Authentication) string {
+	if len(authentication.Tokens.Database) > 0 {
+		return authentication.Tokens.Database
+	}
+	return Main
+}
+
+func getBearerToken(req *http.Request) string {
+	value := req.Header.Get(header.Authorization)
+	if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(value[len(bearerPrefix):])
+}
+
+func containsSuperRole(config auth.Config, roles []string) bool {
+	for _, role := range roles {
+		if config.Roles[role].Super {
+			return true
+		}
+	}
+	return false
+}
+
+func isJwt(token string) bool {
+	return strings.Count(token, ".") == 2
+}
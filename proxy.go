@@ -0,0 +1,167 @@
+package sense
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type forwarded struct {
+	ip       string
+	protocol string
+	host     string
+}
+
+const (
+	protocolHttp  = "http"
+	protocolHttps = "https"
+)
+
+func createTrustedProxies(values []string) []*net.IPNet {
+	result, err := parseIpNets(values)
+	if err != nil {
+		panic(fmt.Errorf("%w: %w", ErrorInvalidProxy, err))
+	}
+	return result
+}
+
+func isTrustedProxy(ip string, proxies []*net.IPNet) bool {
+	return containsIp(ip, proxies)
+}
+
+func isRequestFromTrustedProxy(req *http.Request, proxies []*net.IPNet) bool {
+	return isTrustedProxy(getRemoteIp(req), proxies)
+}
+
+func getForwarded(req *http.Request, proxies []*net.IPNet) forwarded {
+	result := forwarded{
+		ip:       getRemoteIp(req),
+		protocol: protocolHttp,
+		host:     req.Host,
+	}
+	if req.TLS != nil {
+		result.protocol = protocolHttps
+	}
+	if !isTrustedProxy(result.ip, proxies) {
+		return result
+	}
+	nodes := parseForwardedHeader(req.Header.Values(header.Forwarded))
+	if len(nodes) == 0 {
+		nodes = parseXForwardedForHeader(req.Header.Values(header.Ip))
+		if protocol := parseForwardedProtocol(getLastHeaderValue(req, header.ForwardedProto)); len(protocol) > 0 {
+			result.protocol = protocol
+		}
+		if host := parseForwardedHost(getLastHeaderValue(req, header.ForwardedHost)); len(host) > 0 {
+			result.host = host
+		}
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		if len(node.protocol) > 0 {
+			result.protocol = node.protocol
+		}
+		if len(node.host) > 0 {
+			result.host = node.host
+		}
+		if len(node.ip) == 0 {
+			break
+		}
+		result.ip = node.ip
+		if !isTrustedProxy(node.ip, proxies) {
+			break
+		}
+	}
+	return result
+}
+
+func parseForwardedHeader(values []string) []forwarded {
+	result := make([]forwarded, 0)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var node forwarded
+			for _, pair := range strings.Split(element, ";") {
+				key, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				v = strings.Trim(strings.TrimSpace(v), `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					node.ip = parseForwardedIp(v)
+				case "proto":
+					node.protocol = parseForwardedProtocol(v)
+				case "host":
+					node.host = parseForwardedHost(v)
+				}
+			}
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func parseXForwardedForHeader(values []string) []forwarded {
+	result := make([]forwarded, 0)
+	for _, value := range values {
+		for _, ip := range strings.Split(value, ",") {
+			result = append(result, forwarded{ip: parseForwardedIp(strings.TrimSpace(ip))})
+		}
+	}
+	return result
+}
+
+func parseForwardedIp(value string) string {
+	if strings.HasPrefix(value, "[") {
+		end := strings.IndexByte(value, ']')
+		if end == -1 {
+			return ""
+		}
+		value = value[1:end]
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	if net.ParseIP(value) == nil {
+		return ""
+	}
+	return value
+}
+
+func parseForwardedProtocol(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value != protocolHttp && value != protocolHttps {
+		return ""
+	}
+	return value
+}
+
+func parseForwardedHost(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "/\\@ ") {
+		return ""
+	}
+	return value
+}
+
+func getLastHeaderValue(req *http.Request, name string) string {
+	values := req.Header.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	value := values[len(values)-1]
+	if i := strings.LastIndexByte(value, ','); i != -1 {
+		value = value[i+1:]
+	}
+	return strings.TrimSpace(value)
+}
+
+func getRemoteIp(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,105 @@
+package sense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+func TestGetForwarded(t *testing.T) {
+	proxies := createTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})
+	tests := []struct {
+		name     string
+		remote   string
+		headers  map[string][]string
+		expected forwarded
+	}{
+		{
+			name:     "direct client ignores headers",
+			remote:   "203.0.113.9:1234",
+			headers:  map[string][]string{header.Ip: {"198.51.100.1"}, header.ForwardedProto: {"https"}, header.ForwardedHost: {"evil.test"}},
+			expected: forwarded{ip: "203.0.113.9", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "x-forwarded-for from trusted proxy",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Ip: {"198.51.100.1"}, header.ForwardedProto: {"https"}, header.ForwardedHost: {"api.test"}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttps, host: "api.test"},
+		},
+		{
+			name:     "x-forwarded-for skips trusted hops and stops at the first untrusted ip",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Ip: {"192.0.2.7, 198.51.100.1, 10.0.0.2"}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "x-forwarded-for across multiple header lines",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Ip: {"192.0.2.7", "198.51.100.1"}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "spoofed leftmost value is ignored",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Ip: {"10.0.0.5, 198.51.100.1"}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "last proto and host values win",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.ForwardedProto: {"http, https"}, header.ForwardedHost: {"evil.test", "api.test"}},
+			expected: forwarded{ip: "10.0.0.1", protocol: protocolHttps, host: "api.test"},
+		},
+		{
+			name:     "invalid ip and host values are rejected",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Ip: {"not-an-ip"}, header.ForwardedHost: {"evil.test/path"}, header.ForwardedProto: {"gopher"}},
+			expected: forwarded{ip: "10.0.0.1", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "forwarded header",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Forwarded: {`for=192.0.2.7, for="198.51.100.1:4711";proto=https;host=api.test`}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttps, host: "api.test"},
+		},
+		{
+			name:     "forwarded header with ipv6 and trusted hop",
+			remote:   "[fd00::1]:1234",
+			headers:  map[string][]string{header.Forwarded: {`for="[2001:db8::1]:80", for="[fd00::2]"`}},
+			expected: forwarded{ip: "2001:db8::1", protocol: protocolHttp, host: "app.test"},
+		},
+		{
+			name:     "forwarded header takes precedence over x-forwarded-for",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string][]string{header.Forwarded: {"for=198.51.100.1"}, header.Ip: {"192.0.2.7"}},
+			expected: forwarded{ip: "198.51.100.1", protocol: protocolHttp, host: "app.test"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "http://app.test/", nil)
+				req.RemoteAddr = test.remote
+				for name, values := range test.headers {
+					for _, value := range values {
+						req.Header.Add(name, value)
+					}
+				}
+				if result := getForwarded(req, proxies); result != test.expected {
+					t.Fatalf("expected %+v, got %+v", test.expected, result)
+				}
+			},
+		)
+	}
+}
+
+func TestCreateTrustedProxiesRejectsInvalidValues(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected invalid proxy to panic")
+		}
+	}()
+	createTrustedProxies([]string{"10.0.0.0/33"})
+}
@@ -0,0 +1,206 @@
+package sense
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+const (
+	rateLimitCacheKey = "rate-limit"
+)
+
+var (
+	rateLimitBucketScript = atomicScript{
+		lua: redis.NewScript(
+			`local now = tonumber(ARGV[1])
+local tat = tonumber(redis.call('GET', KEYS[1]) or ARGV[1])
+if tat < now then tat = now end
+local next = tat + tonumber(ARGV[2])
+if next - tonumber(ARGV[3]) > now then return {0, tat - now} end
+redis.call('SET', KEYS[1], string.format('%d', next), 'PX', math.max(1, math.ceil((next - now) / 1000)))
+return {1, next - now}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			now, interval, tolerance := args[0], args[1], args[2]
+			tat, ok := values.get(keys[0])
+			if !ok || tat < now {
+				tat = now
+			}
+			next := tat + interval
+			if next-tolerance > now {
+				return []int64{0, tat - now}
+			}
+			values.set(keys[0], next, time.Duration(next-now)*time.Microsecond)
+			return []int64{1, next - now}
+		},
+	}
+	rateLimitWindowScript = atomicScript{
+		lua: redis.NewScript(
+			`local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local window = tonumber(ARGV[2])
+local reset = tonumber(ARGV[4]) + window - tonumber(ARGV[3])
+if previous * reset / window + current + 1 > tonumber(ARGV[1]) then return {0, current, previous} end
+current = redis.call('INCR', KEYS[1])
+if current == 1 then redis.call('PEXPIRE', KEYS[1], math.ceil(2 * window / 1000)) end
+return {1, current, previous}`,
+		),
+		memory: func(values atomicValues, keys []string, args []int64) []int64 {
+			limit, window, now, start := args[0], args[1], args[2], args[3]
+			current, _ := values.get(keys[0])
+			previous, _ := values.get(keys[1])
+			reset := start + window - now
+			if float64(previous)*float64(reset)/float64(window)+float64(current)+1 > float64(limit) {
+				return []int64{0, current, previous}
+			}
+			current++
+			ttl := time.Duration(-1)
+			if current == 1 {
+				ttl = 2 * time.Duration(window) * time.Microsecond
+			}
+			values.set(keys[0], current, ttl)
+			return []int64{1, current, previous}
+		},
+	}
+)
+
+func rateLimitMiddleware(route *Route, rateLimit config.RateLimit, cache atomicCache) Handler {
+	scope := route.Method + " " + route.Host + route.Path
+	return func(c Context) error {
+		key := createRateLimitCacheKey(scope, getRateLimitKey(c, rateLimit))
+		result, err := takeRateLimit(cache, key, rateLimit, time.Now())
+		if err != nil {
+			return c.Send().Status(http.StatusInternalServerError).Error(err)
+		}
+		h := c.Send().Header()
+		h.Set(header.RateLimitLimit, strconv.Itoa(rateLimit.Limit))
+		h.Set(header.RateLimitPolicy, fmt.Sprintf("%d;w=%d", rateLimit.Limit, int(rateLimit.Window.Seconds())))
+		h.Set(header.RateLimitRemaining, strconv.Itoa(result.remaining))
+		h.Set(header.RateLimitReset, strconv.Itoa(ceilSeconds(result.reset)))
+		if !result.allowed {
+			h.Set(header.RetryAfter, strconv.Itoa(ceilSeconds(result.retryAfter)))
+			return c.Send().Status(http.StatusTooManyRequests).Error(ErrorTooManyRequests)
+		}
+		return c.Continue()
+	}
+}
+
+func takeRateLimit(cache atomicCache, key string, rateLimit config.RateLimit, now time.Time) (rateLimitResult, error) {
+	switch rateLimit.Algorithm {
+	case config.RateLimitSlidingWindow:
+		return takeRateLimitWindow(cache, key, rateLimit, now)
+	default:
+		return takeRateLimitBucket(cache, key, rateLimit, now)
+	}
+}
+
+func takeRateLimitBucket(cache atomicCache, key string, rateLimit config.RateLimit, now time.Time) (rateLimitResult, error) {
+	capacity := int64(rateLimit.Limit)
+	if rateLimit.Burst > 0 {
+		capacity = int64(rateLimit.Burst)
+	}
+	interval := max(rateLimit.Window.Microseconds()/int64(rateLimit.Limit), 1)
+	tolerance := capacity * interval
+	values, err := cache.run(rateLimitBucketScript, []string{key}, now.UnixMicro(), interval, tolerance)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	used := values[1]
+	result := rateLimitResult{allowed: values[0] == 1, reset: time.Duration(used) * time.Microsecond}
+	if result.allowed {
+		result.remaining = int((tolerance - used) / interval)
+	}
+	if !result.allowed {
+		result.retryAfter = time.Duration(used+interval-tolerance) * time.Microsecond
+	}
+	return result, nil
+}
+
+func takeRateLimitWindow(cache atomicCache, key string, rateLimit config.RateLimit, now time.Time) (rateLimitResult, error) {
+	window := rateLimit.Window.Microseconds()
+	start := now.UnixMicro() - now.UnixMicro()%window
+	values, err := cache.run(
+		rateLimitWindowScript,
+		[]string{key + "-" + strconv.FormatInt(start, 10), key + "-" + strconv.FormatInt(start-window, 10)},
+		int64(rateLimit.Limit), window, now.UnixMicro(), start,
+	)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	current, previous := values[1], values[2]
+	reset := time.Duration(start+window-now.UnixMicro()) * time.Microsecond
+	weight := float64(reset) / float64(rateLimit.Window)
+	count := float64(previous)*weight + float64(current)
+	result := rateLimitResult{allowed: values[0] == 1, reset: reset}
+	if !result.allowed {
+		result.retryAfter = reset
+		if previous > 0 && current < int64(rateLimit.Limit) {
+			threshold := (float64(rateLimit.Limit) - 1 - float64(current)) / float64(previous)
+			result.retryAfter = reset - time.Duration(threshold*float64(rateLimit.Window))
+		}
+	}
+	result.remaining = int(math.Max(0, math.Floor(float64(rateLimit.Limit)-count)))
+	return result, nil
+}
+
+func getRateLimitKey(c Context, rateLimit config.RateLimit) string {
+	switch rateLimit.Key {
+	case config.RateLimitKeySession:
+		session, err := c.Auth().Session().Get()
+		if err == nil && session.Id > 0 {
+			return config.RateLimitKeySession + ":" + strconv.Itoa(session.Id)
+		}
+	case config.RateLimitKeyApiKey:
+		keyHeader := rateLimit.KeyHeader
+		if len(keyHeader) == 0 {
+			keyHeader = header.XApiKey
+		}
+		if apiKey := c.Request().Header().Get(keyHeader); len(apiKey) > 0 {
+			return config.RateLimitKeyApiKey + ":" + apiKey
+		}
+	}
+	return config.RateLimitKeyIp + ":" + c.Request().Ip()
+}
+
+func createRateLimitCacheKey(scope, key string) string {
+	hash := sha256.Sum256([]byte(scope + " " + key))
+	return rateLimitCacheKey + "-" + hex.EncodeToString(hash[:])
+}
+
+func getRouteRateLimit(route *Route, rateLimit config.RateLimit) (config.RateLimit, bool) {
+	if route.RateLimit == nil {
+		return rateLimit, rateLimit.Enabled && rateLimit.Limit > 0 && rateLimit.Window > 0
+	}
+	r := *route.RateLimit
+	if len(r.Algorithm) == 0 {
+		r.Algorithm = rateLimit.Algorithm
+	}
+	if len(r.Key) == 0 {
+		r.Key = rateLimit.Key
+	}
+	if len(r.KeyHeader) == 0 {
+		r.KeyHeader = rateLimit.KeyHeader
+	}
+	return r, r.Limit > 0 && r.Window > 0
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,106 @@
+package sense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type testRateLimitStep struct {
+	offset  time.Duration
+	allowed bool
+}
+
+func TestRateLimitAlgorithms(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit config.RateLimit
+		steps     []testRateLimitStep
+	}{
+		{
+			name:      "bucket allows limit then refills one token per interval",
+			rateLimit: config.RateLimit{Limit: 3, Window: time.Second},
+			steps: []testRateLimitStep{
+				{0, true}, {0, true}, {0, true}, {0, false},
+				{300 * time.Millisecond, false}, {334 * time.Millisecond, true}, {334 * time.Millisecond, false},
+			},
+		},
+		{
+			name:      "bucket burst",
+			rateLimit: config.RateLimit{Limit: 2, Window: time.Second, Burst: 4},
+			steps: []testRateLimitStep{
+				{0, true}, {0, true}, {0, true}, {0, true}, {0, false}, {500 * time.Millisecond, true},
+			},
+		},
+		{
+			name:      "sliding window weights the previous window",
+			rateLimit: config.RateLimit{Limit: 3, Window: time.Second, Algorithm: config.RateLimitSlidingWindow},
+			steps: []testRateLimitStep{
+				{0, true}, {0, true}, {0, true}, {0, false},
+				{1500 * time.Millisecond, true}, {1500 * time.Millisecond, false},
+				{2999 * time.Millisecond, true}, {2999 * time.Millisecond, true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				cache := createMemoryAtomicCache()
+				start := time.Unix(1700000000, 0)
+				for i, step := range test.steps {
+					result, err := takeRateLimit(cache, "test", test.rateLimit, start.Add(step.offset))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result.allowed != step.allowed {
+						t.Fatalf("step %d: expected allowed %v, got %v", i, step.allowed, result.allowed)
+					}
+					if !result.allowed && result.retryAfter <= 0 {
+						t.Fatalf("step %d: expected retry after, got %s", i, result.retryAfter)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestRateLimitMiddlewareIsolatesApps(t *testing.T) {
+	first, second := createTestRateLimitApp(), createTestRateLimitApp()
+	for i := 0; i < 2; i++ {
+		if res := sendTestRateLimitRequest(first); res.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d", http.StatusOK, res.Code)
+		}
+	}
+	res := sendTestRateLimitRequest(first)
+	if res.Code != http.StatusTooManyRequests || len(res.Header().Get(header.RetryAfter)) == 0 {
+		t.Fatalf("expected %d with retry after, got %d", http.StatusTooManyRequests, res.Code)
+	}
+	if res.Header().Get(header.RateLimitRemaining) != "0" {
+		t.Fatalf("expected no remaining requests, got %s", res.Header().Get(header.RateLimitRemaining))
+	}
+	if res := sendTestRateLimitRequest(second); res.Code != http.StatusOK {
+		t.Fatalf("limits must not be shared between apps, got %d", res.Code)
+	}
+}
+
+func createTestRateLimitApp() Sense {
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	app := New(cfg)
+	app.Get(
+		"/limited", func(c Context) error {
+			return c.Send().Text("ok")
+		},
+	).RateLimit(2, time.Minute)
+	return app
+}
+
+func sendTestRateLimitRequest(app Sense) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	app.(*sense).handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	return res
+}
@@ -1,7 +1,11 @@
 package sense
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
 	"net/http"
+	"regexp"
 	
 	"github.com/creamsensation/sense/internal/constant/header"
 )
@@ -10,6 +14,7 @@ type RequestContext interface {
 	ContentType() string
 	Header() http.Header
 	Host() string
+	Id() string
 	Ip() string
 	Is() RequestIsContext
 	Method() string
@@ -29,9 +34,20 @@ type RequestIsContext interface {
 }
 
 type request struct {
-	req *http.Request
+	req       *http.Request
+	proxies   []*net.IPNet
+	forwarded *forwarded
+	id        string
 }
 
+const (
+	requestIdBytes = 16
+)
+
+var (
+	requestIdMatcher = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+)
+
 func (r *request) ContentType() string {
 	return r.req.Header.Get(header.ContentType)
 }
@@ -41,11 +57,26 @@ func (r *request) Header() http.Header {
 }
 
 func (r *request) Host() string {
-	return r.Protocol() + "://" + r.req.Host
+	return r.Protocol() + "://" + r.getForwarded().host
+}
+
+func (r *request) Id() string {
+	if len(r.id) > 0 {
+		return r.id
+	}
+	r.id = r.req.Header.Get(header.XRequestId)
+	if !isRequestFromTrustedProxy(r.req, r.proxies) || !requestIdMatcher.MatchString(r.id) {
+		bytes := make([]byte, requestIdBytes)
+		if _, err := rand.Read(bytes); err != nil {
+			panic(err)
+		}
+		r.id = hex.EncodeToString(bytes)
+	}
+	return r.id
 }
 
 func (r *request) Ip() string {
-	return r.req.Header.Get("X-Forwarded-For")
+	return r.getForwarded().ip
 }
 
 func (r *request) Is() RequestIsContext {
@@ -65,10 +96,7 @@ func (r *request) Path() string {
 }
 
 func (r *request) Protocol() string {
-	if r.req.TLS == nil {
-		return "http"
-	}
-	return "https"
+	return r.getForwarded().protocol
 }
 
 func (r *request) Raw() *http.Request {
@@ -99,18 +127,48 @@ func (r *request) Delete() bool {
 	return r.req.Method == http.MethodDelete
 }
 
-func PathValue[T Assert](c RequestContext, key string, defaultValue ...T) T {
-	value := c.Raw().PathValue(key)
-	if len(value) == 0 && len(defaultValue) > 0 {
+func PathValue[T any](c RequestContext, key string, defaultValue ...T) T {
+	value, err := ParsePathValue[T](c, key)
+	if err != nil && len(defaultValue) > 0 {
 		return defaultValue[0]
 	}
-	return assertStringToType[T](value)
+	if err != nil {
+		return *new(T)
+	}
+	return value
 }
 
-func Query[T Assert](c RequestContext, key string, defaultValue ...T) T {
-	value := c.Raw().URL.Query().Get(key)
-	if len(value) == 0 && len(defaultValue) > 0 {
+func ParsePathValue[T any](c RequestContext, key string) (T, error) {
+	value := c.Raw().PathValue(key)
+	if len(value) == 0 {
+		return *new(T), ErrorPathValueMissing
+	}
+	return parseStringsToType[T]([]string{value})
+}
+
+func Query[T any](c RequestContext, key string, defaultValue ...T) T {
+	value, err := ParseQuery[T](c, key)
+	if err != nil && len(defaultValue) > 0 {
 		return defaultValue[0]
 	}
-	return assertStringToType[T](value)
+	if err != nil {
+		return *new(T)
+	}
+	return value
+}
+
+func ParseQuery[T any](c RequestContext, key string) (T, error) {
+	values := c.Raw().URL.Query()[key]
+	if len(values) == 0 || (len(values) == 1 && len(values[0]) == 0) {
+		return *new(T), ErrorQueryParamMissing
+	}
+	return parseStringsToType[T](values)
+}
+
+func (r *request) getForwarded() forwarded {
+	if r.forwarded == nil {
+		f := getForwarded(r.req, r.proxies)
+		r.forwarded = &f
+	}
+	return *r.forwarded
 }
@@ -0,0 +1,98 @@
+package sense
+
+import (
+	"time"
+	
+	"github.com/creamsensation/sense/config"
+)
+
+type RouteBuilder interface {
+	Name(name string) RouteBuilder
+	Summary(summary string) RouteBuilder
+	Tags(tags ...string) RouteBuilder
+	Request(value any) RouteBuilder
+	Response(statusCode int, value any) RouteBuilder
+	CsrfExempt() RouteBuilder
+	RateLimit(limit int, window time.Duration, rateLimit ...config.RateLimit) RouteBuilder
+	Require(permissions ...string) RouteBuilder
+	Policy(policies ...Policy) RouteBuilder
+}
+
+type routeBuilder struct {
+	route  *Route
+	routes *[]*Route
+}
+
+func createRouteBuilder(route *Route, routes *[]*Route) RouteBuilder {
+	return &routeBuilder{
+		route:  route,
+		routes: routes,
+	}
+}
+
+func (b *routeBuilder) Name(name string) RouteBuilder {
+	if route := findRouteWithName(name, *b.routes); route != nil && route != b.route {
+		panic(ErrorDuplicateRoute)
+	}
+	b.route.Name = name
+	return b
+}
+
+func (b *routeBuilder) Summary(summary string) RouteBuilder {
+	b.route.Summary = summary
+	return b
+}
+
+func (b *routeBuilder) Tags(tags ...string) RouteBuilder {
+	b.route.Tags = append(b.route.Tags, tags...)
+	return b
+}
+
+func (b *routeBuilder) Request(value any) RouteBuilder {
+	b.route.Request = value
+	return b
+}
+
+func (b *routeBuilder) Response(statusCode int, value any) RouteBuilder {
+	if b.route.Responses == nil {
+		b.route.Responses = make(map[int]any)
+	}
+	b.route.Responses[statusCode] = value
+	return b
+}
+
+func (b *routeBuilder) CsrfExempt() RouteBuilder {
+	b.route.CsrfExempt = true
+	return b
+}
+
+func (b *routeBuilder) RateLimit(limit int, window time.Duration, rateLimit ...config.RateLimit) RouteBuilder {
+	var r config.RateLimit
+	if len(rateLimit) > 0 {
+		r = rateLimit[0]
+	}
+	r.Enabled = true
+	r.Limit = limit
+	r.Window = window
+	b.route.RateLimit = &r
+	return b
+}
+
+func (b *routeBuilder) Require(permissions ...string) RouteBuilder {
+	b.route.Permissions = append(b.route.Permissions, permissions...)
+	return b
+}
+
+func (b *routeBuilder) Policy(policies ...Policy) RouteBuilder {
+	b.route.Policies = append(b.route.Policies, policies...)
+	return b
+}
+
+func findRouteWithName(name string, routes []*Route) *Route {
+	for _, route := range routes {
+		if len(route.Name) > 0 && route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
@@ -1,56 +1,94 @@
 package sense
 
 import (
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
 	"slices"
 	"strings"
 	
-	"github.com/creamsensation/socketer"
-	
 	"github.com/creamsensation/sense/config"
 )
 
 type Router interface {
-	Static(path, dir string) Router
+	Static(path, dir string, options ...StaticOptions) Router
+	StaticFS(path string, fsys fs.FS, options ...StaticOptions) Router
+	Spa(path string, fsys fs.FS, index string, options ...StaticOptions) Router
 	Use(handler Handler) Router
+	Cors(cors config.Cors) Router
 	Group(pathPrefix string) Router
-	Head(path string, handler Handler)
-	Get(path string, handler Handler)
-	Post(path string, handler Handler)
-	Options(path string, handler Handler)
-	Put(path string, handler Handler)
-	Patch(path string, handler Handler)
-	Delete(path string, handler Handler)
-	Ws(path, name string, handler Handler)
+	Host(host string) Router
+	Oidc(options OidcOptions) Router
+	Head(path string, handler Handler) RouteBuilder
+	Get(path string, handler Handler) RouteBuilder
+	Post(path string, handler Handler) RouteBuilder
+	Options(path string, handler Handler) RouteBuilder
+	Put(path string, handler Handler) RouteBuilder
+	Patch(path string, handler Handler) RouteBuilder
+	Delete(path string, handler Handler) RouteBuilder
+	Ws(path, name string, handler Handler, options ...WsOptions) RouteBuilder
 }
 
 type Route struct {
-	Method    string
-	Path      string
-	Firewalls []config.Firewall
+	Name        string            `json:"name"`
+	Method      string            `json:"method"`
+	Host        string            `json:"host"`
+	Path        string            `json:"path"`
+	Handler     string            `json:"handler"`
+	Middlewares []string          `json:"middlewares"`
+	Constraints []RouteConstraint `json:"constraints"`
+	Websocket   string            `json:"websocket"`
+	Roles       []string          `json:"roles"`
+	Permissions []string          `json:"permissions"`
+	Summary     string            `json:"summary"`
+	Tags        []string          `json:"tags"`
+	Request     any               `json:"-"`
+	Responses   map[int]any       `json:"-"`
+	CsrfExempt  bool              `json:"csrfExempt"`
+	RateLimit   *config.RateLimit `json:"-"`
+	Firewalls   []config.Firewall `json:"-"`
+	Policies    []Policy          `json:"-"`
+
+	firewallRules []firewallRule
 }
 
 type router struct {
 	config      Config
 	mux         *http.ServeMux
+	host        string
+	hosts       map[string]*hostDispatcher
 	pathPrefix  string
 	middlewares []Handler
-	routes      *[]Route
-	options     []string
-	heads       []string
-	ws          map[string]socketer.Ws
+	constraints []RouteConstraint
+	routes      *[]*Route
+	handled     map[string]bool
+	preflights  map[string]*preflight
+	cors        *cors
+	csrfExempt  []*regexp.Regexp
+	proxies     []*net.IPNet
+	atomic      atomicCache
+	ws          map[string]*wsHub
 }
 
 func createRouter(args routerArgs) *router {
 	return &router{
 		config:      args.config,
 		mux:         args.mux,
+		host:        args.host,
+		hosts:       args.hosts,
 		pathPrefix:  args.pathPrefix,
 		middlewares: args.middlewares,
+		constraints: args.constraints,
 		routes:      args.routes,
-		options:     make([]string, 0),
-		heads:       make([]string, 0),
-		ws:          make(map[string]socketer.Ws),
+		handled:     args.handled,
+		preflights:  args.preflights,
+		cors:        args.cors,
+		csrfExempt:  args.csrfExempt,
+		proxies:     args.proxies,
+		atomic:      args.atomic,
+		ws:          make(map[string]*wsHub),
 	}
 }
 
@@ -59,204 +97,367 @@ func (r *router) Use(handler Handler) Router {
 	return r
 }
 
-func (r *router) Static(path, dir string) Router {
-	path = formatPath(path) + "/"
-	r.mux.Handle(http.MethodGet+" "+path, http.StripPrefix(path, http.FileServer(http.Dir(dir))))
+func (r *router) Cors(cors config.Cors) Router {
+	r.cors = createCors(cors)
+	return r
+}
+
+func (r *router) Static(path, dir string, options ...StaticOptions) Router {
+	return r.StaticFS(path, os.DirFS(dir), options...)
+}
+
+func (r *router) StaticFS(path string, fsys fs.FS, options ...StaticOptions) Router {
+	path = r.pathPrefix + formatPath(path) + "/"
+	route := &Route{
+		Method:  http.MethodGet,
+		Host:    r.host,
+		Path:    path,
+		Handler: staticHandlerName,
+	}
+	*r.routes = append(*r.routes, route)
+	r.handleFunc(http.MethodGet+" "+path, http.StripPrefix(path, http.HandlerFunc(createStatic(fsys, options...).serveHTTP)).ServeHTTP)
+	return r
+}
+
+func (r *router) Spa(path string, fsys fs.FS, index string, options ...StaticOptions) Router {
+	var o StaticOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+	o.Index = index
+	path = r.pathPrefix + formatPath(path) + "/"
+	route := &Route{
+		Method:  http.MethodGet,
+		Host:    r.host,
+		Path:    path,
+		Handler: spaHandlerName,
+	}
+	*r.routes = append(*r.routes, route)
+	r.handleFunc(http.MethodGet+" "+path, http.StripPrefix(path, http.HandlerFunc(createStatic(fsys, o).serveSpa)).ServeHTTP)
 	return r
 }
 
 func (r *router) Group(pathPrefix string) Router {
+	pathPrefix, constraints := createRoutePath(pathPrefix)
 	return createRouter(
 		routerArgs{
 			config:      r.config,
 			mux:         r.mux,
 			routes:      r.routes,
-			pathPrefix:  r.pathPrefix + formatPath(pathPrefix),
+			proxies:     r.proxies,
+			atomic:      r.atomic,
+			host:        r.host,
+			hosts:       r.hosts,
+			handled:     r.handled,
+			preflights:  r.preflights,
+			cors:        r.cors,
+			csrfExempt:  r.csrfExempt,
+			pathPrefix:  r.pathPrefix + pathPrefix,
 			middlewares: r.middlewares,
+			constraints: append(slices.Clone(r.constraints), constraints...),
 		},
 	)
 }
 
-func (r *router) Ws(path, name string, handler Handler) {
-	path = formatPath(path)
-	r.ws[name] = socketer.New()
-	route := r.addRoute("WS", path)
-	r.mux.HandleFunc(
+func (r *router) Host(host string) Router {
+	host, constraints := createRoutePath(strings.ToLower(host))
+	return createRouter(
+		routerArgs{
+			config:      r.config,
+			mux:         r.mux,
+			routes:      r.routes,
+			proxies:     r.proxies,
+			atomic:      r.atomic,
+			host:        host,
+			hosts:       r.hosts,
+			handled:     r.handled,
+			preflights:  r.preflights,
+			cors:        r.cors,
+			csrfExempt:  r.csrfExempt,
+			pathPrefix:  r.pathPrefix,
+			middlewares: r.middlewares,
+			constraints: append(slices.Clone(r.constraints), constraints...),
+		},
+	)
+}
+
+func (r *router) Oidc(options OidcOptions) Router {
+	r.Get(oidcLoginPath, createOidcLoginHandler(options)).Name(OidcLoginRoute)
+	r.Get(oidcCallbackPath, createOidcCallbackHandler(options)).Name(OidcCallbackRoute)
+	return r
+}
+
+func (r *router) Ws(path, name string, handler Handler, options ...WsOptions) RouteBuilder {
+	var o WsOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+	path, constraints := createRoutePath(path)
+	r.ws[name] = createWsHub()
+	route := r.addRoute("WS", path, constraints, handler)
+	route.Websocket = name
+	r.handleFunc(
 		createRoutePattern("", r.pathPrefix, path),
 		createWsHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
 				ws:          r.ws,
 				name:        name,
+				wsOptions:   o,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Get(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodGet, path)
+func (r *router) Get(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodGet, path, constraints, handler)
+	r.createOptionsHandleFunc(path, route)
 	r.createHeadHandleFunc(path, route)
-	r.createCanonicalHandleFunc(http.MethodGet, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodGet, r.pathPrefix, path),
+	r.handleRoute(
+		http.MethodGet, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Post(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodPost, path)
+func (r *router) Post(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodPost, path, constraints, handler)
 	r.createOptionsHandleFunc(path, route)
-	r.createCanonicalHandleFunc(http.MethodPost, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodPost, r.pathPrefix, path),
+	r.handleRoute(
+		http.MethodPost, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Put(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodPut, path)
-	r.createCanonicalHandleFunc(http.MethodPut, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodPut, r.pathPrefix, path),
+func (r *router) Put(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodPut, path, constraints, handler)
+	r.createOptionsHandleFunc(path, route)
+	r.handleRoute(
+		http.MethodPut, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Patch(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodPatch, path)
-	r.createCanonicalHandleFunc(http.MethodPatch, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodPatch, r.pathPrefix, path),
+func (r *router) Patch(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodPatch, path, constraints, handler)
+	r.createOptionsHandleFunc(path, route)
+	r.handleRoute(
+		http.MethodPatch, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Delete(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodDelete, path)
-	r.createCanonicalHandleFunc(http.MethodDelete, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodDelete, r.pathPrefix, path),
+func (r *router) Delete(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodDelete, path, constraints, handler)
+	r.createOptionsHandleFunc(path, route)
+	r.handleRoute(
+		http.MethodDelete, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Options(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodOptions, path)
-	r.createCanonicalHandleFunc(http.MethodOptions, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodOptions, r.pathPrefix, path),
-		createHandlerFunc(
-			handlerFuncArgs{
-				config:      r.config,
-				route:       route,
-				handler:     handler,
-				middlewares: r.middlewares,
-			},
-		),
+func (r *router) Options(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodOptions, path, constraints, handler)
+	key := http.MethodOptions + " " + route.Host + route.Path
+	p, ok := r.preflights[key]
+	if ok && p.handler != nil {
+		panic(ErrorDuplicatePattern)
+	}
+	if !ok {
+		p = createPreflight()
+		r.preflights[key] = p
+		r.handleRoute(http.MethodOptions, path, p.serveHTTP)
+	}
+	p.handler = createHandlerFunc(
+		handlerFuncArgs{
+			config:      r.config,
+			route:       route,
+			routes:      r.routes,
+			proxies:     r.proxies,
+			atomic:      r.atomic,
+			handler:     handler,
+			middlewares: r.middlewares,
+			cors:        r.cors,
+		},
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) Head(path string, handler Handler) {
-	path = formatPath(path)
-	route := r.addRoute(http.MethodHead, path)
-	r.createCanonicalHandleFunc(http.MethodHead, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodHead, r.pathPrefix, path),
+func (r *router) Head(path string, handler Handler) RouteBuilder {
+	path, constraints := createRoutePath(path)
+	route := r.addRoute(http.MethodHead, path, constraints, handler)
+	r.handleRoute(
+		http.MethodHead, path,
 		createHandlerFunc(
 			handlerFuncArgs{
 				config:      r.config,
 				route:       route,
+				routes:      r.routes,
+				proxies:     r.proxies,
+				atomic:      r.atomic,
 				handler:     handler,
 				middlewares: r.middlewares,
+				cors:        r.cors,
 			},
 		),
 	)
+	return createRouteBuilder(route, r.routes)
 }
 
-func (r *router) createCanonicalHandleFunc(method string, path string) {
-	if !strings.HasSuffix(path, "/") {
-		r.mux.HandleFunc(
-			createRoutePattern(method, r.pathPrefix, path+"/"),
-			createHandlerCanonicalRedirect(),
-		)
+func (r *router) handleRoute(method string, path string, handler http.HandlerFunc) {
+	pattern := createRoutePattern(method, r.pathPrefix, path)
+	if len(r.pathPrefix+path) == 0 {
+		r.handleFunc(createRoutePattern(method, "", "/{$}"), handler)
+		return
+	}
+	if strings.HasSuffix(path, "...}") || strings.HasSuffix(path, "{$}") {
+		r.handleFunc(pattern, handler)
+		return
+	}
+	slashPattern := createRoutePattern(method, r.pathPrefix, path+"/{$}")
+	switch r.config.Router.TrailingSlash {
+	case config.TrailingSlashAppend:
+		r.handleFunc(slashPattern, handler)
+		r.handleFunc(pattern, createTrailingSlashRedirect(config.TrailingSlashAppend, r.proxies))
+	case config.TrailingSlashIgnore:
+		r.handleFunc(pattern, handler)
+	case config.TrailingSlashBoth:
+		r.handleFunc(pattern, handler)
+		r.handleFunc(slashPattern, handler)
+	default:
+		r.handleFunc(pattern, handler)
+		r.handleFunc(slashPattern, createTrailingSlashRedirect(config.TrailingSlashStrip, r.proxies))
 	}
 }
 
-func (r *router) createOptionsHandleFunc(path string, route Route) {
-	if slices.Contains(r.options, route.Path) {
-		return
+func (r *router) createOptionsHandleFunc(path string, route *Route) {
+	key := http.MethodOptions + " " + route.Host + route.Path
+	p, ok := r.preflights[key]
+	if !ok {
+		p = createPreflight()
+		r.preflights[key] = p
+		r.handleRoute(http.MethodOptions, path, p.serveHTTP)
+	}
+	p.add(route.Method, r.cors)
+	if route.Method == http.MethodGet {
+		p.add(http.MethodHead, r.cors)
 	}
-	r.options = append(r.options, route.Path)
-	r.createCanonicalHandleFunc(http.MethodOptions, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodOptions, r.pathPrefix, path),
-		createEmptyHandlerResponse(),
-	)
 }
 
-func (r *router) createHeadHandleFunc(path string, route Route) {
-	if slices.Contains(r.heads, route.Path) {
+func (r *router) createHeadHandleFunc(path string, route *Route) {
+	key := http.MethodHead + " " + route.Host + route.Path
+	if r.handled[key] {
 		return
 	}
-	r.heads = append(r.heads, route.Path)
-	r.createCanonicalHandleFunc(http.MethodHead, path)
-	r.mux.HandleFunc(
-		createRoutePattern(http.MethodHead, r.pathPrefix, path),
+	r.handled[key] = true
+	r.handleRoute(
+		http.MethodHead, path,
 		createEmptyHandlerResponse(),
 	)
 }
 
-func (r *router) addRoute(method string, path string) Route {
+func (r *router) addRoute(method string, path string, constraints []RouteConstraint, handler Handler) *Route {
 	p := r.pathPrefix + path
-	route := Route{
-		Method:    method,
-		Path:      p,
-		Firewalls: findFirewallsWithPath(p, r.config.Security.Firewalls),
+	firewalls := findFirewallsWithRoute(p, r.config.Security.Firewalls)
+	route := &Route{
+		Method:      method,
+		Host:        r.host,
+		Path:        p,
+		Handler:     getFuncName(handler),
+		Middlewares: getFuncNames(r.middlewares),
+		Constraints: append(slices.Clone(r.constraints), constraints...),
+		Roles:       getFirewallsRoles(firewalls),
+		CsrfExempt:  isCsrfExemptPath(p, r.csrfExempt),
+		Firewalls:   firewalls,
+
+		firewallRules: createFirewallRules(firewalls),
 	}
 	*r.routes = append(*r.routes, route)
 	return route
 }
+
+func (r *router) handleFunc(pattern string, handler http.HandlerFunc) {
+	if len(r.host) > 0 && !isHostWildcard(r.host) {
+		r.mux.HandleFunc(createHostPattern(pattern, r.host), handler)
+		return
+	}
+	dispatcher, ok := r.hosts[pattern]
+	if !ok {
+		dispatcher = createHostDispatcher()
+		r.hosts[pattern] = dispatcher
+		r.mux.HandleFunc(pattern, dispatcher.serveHTTP)
+	}
+	dispatcher.add(r.host, handler)
+}
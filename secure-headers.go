@@ -0,0 +1,120 @@
+package sense
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+const (
+	CspNonce = "{nonce}"
+)
+
+const (
+	defaultHsts               = 365 * 24 * 60 * 60
+	defaultCsp                = "default-src 'self'; script-src 'self' 'nonce-" + CspNonce + "'; style-src 'self' 'nonce-" + CspNonce + "'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+	defaultFrameOptions       = "DENY"
+	defaultReferrerPolicy     = "strict-origin-when-cross-origin"
+	defaultPermissionsPolicy  = "camera=(), microphone=(), geolocation=()"
+	defaultContentTypeOptions = "nosniff"
+	cspNonceBytes             = 16
+	cspReportLimit            = 64 << 10
+)
+
+type nonceContextKey struct{}
+
+func createSecureHeadersHandler(config Config, handler http.Handler) http.Handler {
+	if !config.Security.Headers.Enabled {
+		return handler
+	}
+	return http.HandlerFunc(
+		func(res http.ResponseWriter, req *http.Request) {
+			nonce := createNonce()
+			applySecureHeaders(res, config.Router, config.Security.Headers, nonce)
+			handler.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), nonceContextKey{}, nonce)))
+		},
+	)
+}
+
+func getRequestNonce(req *http.Request) string {
+	nonce, _ := req.Context().Value(nonceContextKey{}).(string)
+	return nonce
+}
+
+func createNonce() string {
+	bytes := make([]byte, cspNonceBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(bytes)
+}
+
+func applySecureHeaders(res http.ResponseWriter, routerConfig config.Router, headers config.Headers, nonce string) {
+	h := res.Header()
+	if headers.Hsts >= 0 {
+		maxAge := int(headers.Hsts.Seconds())
+		if maxAge == 0 {
+			maxAge = defaultHsts
+		}
+		hsts := fmt.Sprintf("max-age=%d", maxAge)
+		if headers.HstsSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		h.Set(header.StrictTransportSecurity, hsts)
+	}
+	csp := getValueOrDefault(headers.Csp, defaultCsp)
+	if csp != "-" {
+		csp = strings.ReplaceAll(csp, CspNonce, nonce)
+		if len(headers.CspReportPath) > 0 {
+			csp += "; report-uri " + formatPath(routerConfig.Prefix) + formatPath(headers.CspReportPath)
+		}
+		if headers.CspReportOnly {
+			h.Set(header.ContentSecurityPolicyReportOnly, csp)
+		} else {
+			h.Set(header.ContentSecurityPolicy, csp)
+		}
+	}
+	setHeaderValueOrDefault(h, header.XFrameOptions, headers.FrameOptions, defaultFrameOptions)
+	setHeaderValueOrDefault(h, header.ReferrerPolicy, headers.ReferrerPolicy, defaultReferrerPolicy)
+	setHeaderValueOrDefault(h, header.PermissionsPolicy, headers.PermissionsPolicy, defaultPermissionsPolicy)
+	setHeaderValueOrDefault(h, header.XContentTypeOptions, headers.ContentTypeOptions, defaultContentTypeOptions)
+}
+
+func setHeaderValueOrDefault(h http.Header, key, value, defaultValue string) {
+	value = getValueOrDefault(value, defaultValue)
+	if value == "-" {
+		return
+	}
+	h.Set(key, value)
+}
+
+func getValueOrDefault(value, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func createCspReportHandlerFunc(headers config.Headers) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		report, err := io.ReadAll(io.LimitReader(req.Body, cspReportLimit))
+		if err != nil {
+			http.Error(res, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if headers.CspReporter != nil {
+			headers.CspReporter(report)
+		} else {
+			log.Printf("csp report: %s\n", report)
+		}
+		res.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -4,10 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	
-	"github.com/creamsensation/socketer"
-	
-	"github.com/creamsensation/auth"
+
 	"github.com/creamsensation/sense/internal/constant/contentType"
 	"github.com/creamsensation/sense/internal/constant/dataType"
 )
@@ -27,9 +24,10 @@ type SendContext interface {
 }
 
 type sender struct {
-	auth        auth.Manager
+	principal   func() (Principal, error)
 	request     *request
-	ws          map[string]socketer.Ws
+	ws          map[string]*wsHub
+	conn        *wsConn
 	res         http.ResponseWriter
 	bytes       []byte
 	dataType    string
@@ -78,11 +76,10 @@ func (s *sender) Json(value any) error {
 }
 
 func (s *sender) Html(value string) error {
-	bytes, err := wrapResult(value)
-	s.bytes = bytes
+	s.bytes = []byte(value)
 	s.dataType = dataType.Html
 	s.contentType = contentType.Html
-	return err
+	return nil
 }
 
 func (s *sender) Xml(value string) error {
@@ -127,5 +124,5 @@ func (s *sender) Ws(name string) WsWriter {
 	if _, ok := s.ws[name]; !ok {
 		panic(ErrorInvalidWebsocket)
 	}
-	return createWsWriter(s.ws, name, s.auth)
+	return createWsWriter(s.ws, name, s.principal, s.conn)
 }
@@ -6,47 +6,111 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"text/tabwriter"
+	
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
 )
 
 type Sense interface {
 	Router
+	Routes() []Route
+	Openapi(host ...string) map[string]any
 	Run(address string)
 }
 
 type sense struct {
 	context.Context
 	*router
-	config Config
-	mux    *http.ServeMux
-	routes *[]Route
+	config  Config
+	mux     *http.ServeMux
+	handler http.Handler
+	routes  *[]*Route
 }
 
 func New(config Config) Sense {
+	validateAudit(config.Security.Audit, config.Database)
 	mux := http.NewServeMux()
-	routes := make([]Route, 0)
-	return &sense{
+	routes := make([]*Route, 0)
+	s := &sense{
 		Context: context.Background(),
 		router: createRouter(
 			routerArgs{
 				config:      config,
 				mux:         mux,
 				routes:      &routes,
+				hosts:       make(map[string]*hostDispatcher),
+				handled:     make(map[string]bool),
+				preflights:  make(map[string]*preflight),
+				cors:        createCors(config.Security.Cors),
+				csrfExempt:  createCsrfExempt(config.Security.Csrf),
+				proxies:     createTrustedProxies(config.Router.TrustedProxies),
+				atomic:      createAtomicCache(config.Cache),
 				pathPrefix:  formatPath(config.Router.Prefix),
 				middlewares: []Handler{},
 			},
 		),
-		config: config,
-		mux:    mux,
-		routes: &routes,
+		config:  config,
+		mux:     mux,
+		handler: createSecureHeadersHandler(config, mux),
+		routes:  &routes,
 	}
+	if len(config.Router.RoutesPath) > 0 {
+		mux.HandleFunc(
+			createRoutePattern(http.MethodGet, formatPath(config.Router.Prefix), formatPath(config.Router.RoutesPath)),
+			createRoutesHandlerFunc(s),
+		)
+	}
+	if config.Security.Headers.Enabled && len(config.Security.Headers.CspReportPath) > 0 {
+		mux.HandleFunc(
+			createRoutePattern(http.MethodPost, formatPath(config.Router.Prefix), formatPath(config.Security.Headers.CspReportPath)),
+			createCspReportHandlerFunc(config.Security.Headers),
+		)
+	}
+	if len(config.Openapi.Path) > 0 {
+		mux.HandleFunc(
+			createRoutePattern(http.MethodGet, formatPath(config.Router.Prefix), formatPath(config.Openapi.Path)),
+			createOpenapiHandlerFunc(s),
+		)
+	}
+	if len(config.Openapi.Path) > 0 && len(config.Openapi.ViewerPath) > 0 {
+		mux.HandleFunc(
+			createRoutePattern(http.MethodGet, formatPath(config.Router.Prefix), formatPath(config.Openapi.ViewerPath)),
+			createOpenapiViewerHandlerFunc(config),
+		)
+		mux.HandleFunc(
+			createRoutePattern(http.MethodGet, formatPath(config.Router.Prefix), formatPath(config.Openapi.ViewerPath)+openapiViewerAsset),
+			createOpenapiViewerAssetHandlerFunc(),
+		)
+	}
+	return s
+}
+
+func (s *sense) Openapi(host ...string) map[string]any {
+	var h string
+	if len(host) > 0 {
+		h = host[0]
+	}
+	return createOpenapiDocument(s.config, s.Routes(), h)
+}
+
+func (s *sense) Routes() []Route {
+	result := make([]Route, len(*s.routes))
+	for i, route := range *s.routes {
+		result[i] = *route
+	}
+	return result
 }
 
 func (s *sense) Run(address string) {
 	s.beforeRun(address)
-	log.Fatalln(http.ListenAndServe(address, s.mux))
+	log.Fatalln(http.ListenAndServe(address, s.handler))
 }
 
 func (s *sense) beforeRun(address string) {
+	if s.config.Router.Quiet {
+		return
+	}
 	s.printRoutes()
 	if strings.HasPrefix(address, ":") {
 		address = "localhost" + address
@@ -64,9 +128,37 @@ func (s *sense) printRoutes() {
 	fmt.Println(WhiteColor.Underline(true).Bold(true).Render("Routes:"))
 	for _, route := range *s.routes {
 		fmt.Printf(
-			"%s %s\n", EmeraldColor.Bold(true).Underline(false).Render(route.Method),
-			WhiteColor.Bold(false).Underline(false).Render(route.Path),
+			"%s %s %s\n", EmeraldColor.Bold(true).Underline(false).Render(route.Method),
+			WhiteColor.Bold(false).Underline(false).Render(route.Host+route.Path),
+			BlueColor.Render(route.Name),
 		)
 	}
 	fmt.Println(Divider)
 }
+
+func createRoutesHandlerFunc(s Sense) func(http.ResponseWriter, *http.Request) {
+	return func(res http.ResponseWriter, req *http.Request) {
+		routes := s.Routes()
+		if req.URL.Query().Get("format") == "table" {
+			res.Header().Set(header.ContentType, contentType.Text)
+			w := tabwriter.NewWriter(res, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "METHOD\tHOST\tPATH\tNAME\tHANDLER\tROLES\tPERMISSIONS")
+			for _, route := range routes {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, route.Host, route.Path, route.Name, route.Handler, strings.Join(route.Roles, ","), strings.Join(route.Permissions, ","))
+			}
+			if err := w.Flush(); err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		bytes, err := wrapResult(routes)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set(header.ContentType, contentType.Json)
+		if _, err = res.Write(bytes); err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package sense
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/cache"
+)
+
+type SessionContext interface {
+	Current() string
+	List(userId int) ([]SessionInfo, error)
+	Revoke(userId int, id string) error
+	RevokeAll(userId int, except ...string) error
+}
+
+type SessionInfo struct {
+	Id           string    `json:"id"`
+	Device       string    `json:"device"`
+	Ip           string    `json:"ip"`
+	UserAgent    string    `json:"userAgent"`
+	Current      bool      `json:"current"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+type SessionLogout struct {
+	Type    string `json:"type"`
+	Session string `json:"session"`
+}
+
+type sessions struct {
+	ctx *handlerContext
+}
+
+type sessionRecord struct {
+	Id           string    `json:"id"`
+	Token        string    `json:"token"`
+	Ip           string    `json:"ip"`
+	UserAgent    string    `json:"userAgent"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+const (
+	SessionLogoutEvent = "session.logout"
+)
+
+const (
+	sessionsCacheKey        = "sessions"
+	sessionActivityInterval = time.Minute
+)
+
+var (
+	sessionBrowsers = [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	sessionSystems = [][2]string{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+func (s *sessions) Current() string {
+	token := s.ctx.cookie.Get(auth.SessionCookieKey)
+	if len(token) == 0 {
+		return ""
+	}
+	return createSessionId(token)
+}
+
+func (s *sessions) List(userId int) ([]SessionInfo, error) {
+	records, err := getSessionRecords(s.ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	current := s.Current()
+	result := make([]SessionInfo, 0, len(records))
+	for _, record := range records {
+		result = append(
+			result, SessionInfo{
+				Id:           record.Id,
+				Device:       getSessionDevice(record.UserAgent),
+				Ip:           record.Ip,
+				UserAgent:    record.UserAgent,
+				Current:      record.Id == current,
+				CreatedAt:    record.CreatedAt,
+				LastActivity: record.LastActivity,
+			},
+		)
+	}
+	slices.SortFunc(
+		result, func(a, b SessionInfo) int {
+			return b.LastActivity.Compare(a.LastActivity)
+		},
+	)
+	return result, nil
+}
+
+func (s *sessions) Revoke(userId int, id string) error {
+	return s.revoke(
+		userId, func(record sessionRecord) bool {
+			return record.Id == id
+		},
+	)
+}
+
+func (s *sessions) RevokeAll(userId int, except ...string) error {
+	return s.revoke(
+		userId, func(record sessionRecord) bool {
+			return !slices.Contains(except, record.Id)
+		},
+	)
+}
+
+func (s *sessions) revoke(userId int, match func(record sessionRecord) bool) error {
+	records, err := getSessionRecords(s.ctx, userId)
+	if err != nil {
+		return err
+	}
+	client := s.ctx.Cache()
+	current := s.Current()
+	revoked := make([]string, 0)
+	for _, record := range records {
+		if !match(record) {
+			continue
+		}
+		if err := client.Destroy(auth.SessionCacheKey + ":" + record.Token); err != nil {
+			return err
+		}
+		if err := client.Destroy(createSessionCacheKey(userId, record.Id)); err != nil {
+			return err
+		}
+		if record.Id == current {
+			s.ctx.cookie.Destroy(auth.SessionCookieKey)
+			s.ctx.principal = nil
+		}
+		revoked = append(revoked, record.Id)
+	}
+	if len(revoked) == 0 {
+		return nil
+	}
+	if err := s.ctx.atomic.remove(createSessionsCacheKey(userId), revoked...); err != nil {
+		return err
+	}
+	s.notify(revoked)
+	return nil
+}
+
+func (s *sessions) notify(ids []string) {
+	for _, id := range ids {
+		bytes, err := json.Marshal(SessionLogout{Type: SessionLogoutEvent, Session: id})
+		if err != nil {
+			continue
+		}
+		for _, hub := range s.ctx.send.ws {
+			hub.closeSession(id, bytes)
+		}
+	}
+}
+
+func trackSession(c *handlerContext, session auth.Session) error {
+	token := c.cookie.Get(auth.SessionCookieKey)
+	if len(token) == 0 || session.Id == 0 {
+		return nil
+	}
+	client := c.Cache()
+	now := time.Now()
+	id := createSessionId(token)
+	key := createSessionCacheKey(session.Id, id)
+	record := sessionRecord{
+		Id:           id,
+		Token:        token,
+		Ip:           c.request.Ip(),
+		UserAgent:    c.request.UserAgent(),
+		CreatedAt:    now,
+		LastActivity: now,
+	}
+	var existing sessionRecord
+	if err := client.Get(key, &existing); err != nil {
+		return err
+	}
+	if len(existing.Id) > 0 {
+		if now.Sub(existing.LastActivity) < sessionActivityInterval {
+			return nil
+		}
+		record.CreatedAt = existing.CreatedAt
+	}
+	duration := getSessionDuration(c.config.Security.Auth)
+	if err := client.Set(key, record, duration); err != nil {
+		return err
+	}
+	return c.atomic.add(createSessionsCacheKey(session.Id), id, duration)
+}
+
+func getSessionRecords(c *handlerContext, userId int) ([]sessionRecord, error) {
+	client := c.Cache()
+	index := c.atomic
+	ids, err := index.members(createSessionsCacheKey(userId))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]sessionRecord, 0, len(ids))
+	expired := make([]string, 0)
+	for _, id := range ids {
+		var record sessionRecord
+		if err := client.Get(createSessionCacheKey(userId, id), &record); err != nil {
+			return nil, err
+		}
+		if len(record.Id) == 0 || !client.Exists(auth.SessionCacheKey+":"+record.Token) {
+			expired = append(expired, id)
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(expired) > 0 {
+		if err := index.remove(createSessionsCacheKey(userId), expired...); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func isSessionActive(client cache.Client, token string) bool {
+	return len(token) > 0 && client.Exists(auth.SessionCacheKey+":"+token)
+}
+
+func getSessionDevice(userAgent string) string {
+	result := make([]string, 0, 2)
+	for _, items := range [][][2]string{sessionBrowsers, sessionSystems} {
+		for _, item := range items {
+			if strings.Contains(userAgent, item[0]) {
+				result = append(result, item[1])
+				break
+			}
+		}
+	}
+	return strings.Join(result, " on ")
+}
+
+func createSessionId(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:16])
+}
+
+func createSessionsCacheKey(userId int) string {
+	return sessionsCacheKey + "-" + strconv.Itoa(userId)
+}
+
+func createSessionCacheKey(userId int, id string) string {
+	return createSessionsCacheKey(userId) + "-" + id
+}
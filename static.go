@@ -0,0 +1,228 @@
+package sense
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+type StaticOptions struct {
+	Listing       bool
+	MaxAge        time.Duration
+	Fingerprint   *regexp.Regexp
+	Precompressed bool
+	Index         string
+	Exclude       []string
+}
+
+type static struct {
+	fs      fs.FS
+	options StaticOptions
+	etags   *sync.Map
+}
+
+const (
+	staticHandlerName    = "static"
+	spaHandlerName       = "spa"
+	staticIndex          = "index.html"
+	staticImmutableCache = "public, max-age=31536000, immutable"
+	staticNoCache        = "no-cache"
+)
+
+var (
+	staticFingerprintMatcher = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[a-zA-Z0-9]+$`)
+	staticEncodings          = []staticEncoding{
+		{name: "br", suffix: ".br"},
+		{name: "gzip", suffix: ".gz"},
+	}
+)
+
+type staticEncoding struct {
+	name   string
+	suffix string
+}
+
+func createStatic(fsys fs.FS, options ...StaticOptions) *static {
+	var o StaticOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+	if o.Fingerprint == nil {
+		o.Fingerprint = staticFingerprintMatcher
+	}
+	if len(o.Index) == 0 {
+		o.Index = staticIndex
+	}
+	return &static{
+		fs:      fsys,
+		options: o,
+		etags:   &sync.Map{},
+	}
+}
+
+func (s *static) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if len(name) == 0 {
+		name = "."
+	}
+	if !s.serveFile(res, req, name) {
+		http.NotFound(res, req)
+	}
+}
+
+func (s *static) serveSpa(res http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if len(name) == 0 {
+		name = "."
+	}
+	if s.serveFile(res, req, name) {
+		return
+	}
+	if len(path.Ext(name)) > 0 || !acceptsHtml(req) || isSpaExcluded(name, s.options.Exclude) || !s.serveFile(res, req, s.options.Index) {
+		http.NotFound(res, req)
+	}
+}
+
+func (s *static) serveFile(res http.ResponseWriter, req *http.Request, name string) bool {
+	info, err := fs.Stat(s.fs, name)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		index := path.Join(name, s.options.Index)
+		if indexInfo, err := fs.Stat(s.fs, index); err == nil && !indexInfo.IsDir() {
+			return s.serveFile(res, req, index)
+		}
+		if !s.options.Listing {
+			return false
+		}
+		if name != "." && !strings.HasSuffix(req.URL.Path, "/") {
+			http.Redirect(res, req, path.Base(req.URL.Path)+"/", http.StatusMovedPermanently)
+			return true
+		}
+		http.FileServerFS(s.fs).ServeHTTP(res, createStaticRequest(req, name))
+		return true
+	}
+	filename := name
+	res.Header().Add(header.Vary, header.AcceptEncoding)
+	if s.options.Precompressed {
+		for _, encoding := range staticEncodings {
+			if !acceptsEncoding(req, encoding.name) {
+				continue
+			}
+			if encodedInfo, err := fs.Stat(s.fs, name+encoding.suffix); err == nil && !encodedInfo.IsDir() {
+				filename = name + encoding.suffix
+				info = encodedInfo
+				res.Header().Set(header.ContentEncoding, encoding.name)
+				break
+			}
+		}
+	}
+	if ct := mime.TypeByExtension(path.Ext(name)); len(ct) > 0 {
+		res.Header().Set(header.ContentType, ct)
+	}
+	res.Header().Set(header.CacheControl, s.createCacheControl(name))
+	etag, err := s.createEtag(filename, info)
+	if err != nil {
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return true
+	}
+	res.Header().Set(header.ETag, etag)
+	f, err := s.fs.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return true
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(res, req, name, info.ModTime(), content)
+	return true
+}
+
+func (s *static) createCacheControl(name string) string {
+	if s.options.Fingerprint.MatchString(path.Base(name)) {
+		return staticImmutableCache
+	}
+	if s.options.MaxAge > 0 {
+		return fmt.Sprintf("public, max-age=%d", int(s.options.MaxAge.Seconds()))
+	}
+	return staticNoCache
+}
+
+func (s *static) createEtag(name string, info fs.FileInfo) (string, error) {
+	key := fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano())
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", errors.Join(ErrorReadData, err)
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+func createStaticRequest(req *http.Request, name string) *http.Request {
+	r := req.Clone(req.Context())
+	r.URL.Path = "/" + strings.TrimPrefix(name, ".")
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		r.URL.Path += "/"
+	}
+	return r
+}
+
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	for _, item := range strings.Split(req.Header.Get(header.AcceptEncoding), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+func acceptsHtml(req *http.Request) bool {
+	return strings.Contains(req.Header.Get(header.Accept), "text/html")
+}
+
+func isSpaExcluded(name string, exclude []string) bool {
+	name = "/" + name
+	for _, prefix := range exclude {
+		prefix = "/" + strings.Trim(prefix, "/")
+		if name == prefix || strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
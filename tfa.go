@@ -0,0 +1,490 @@
+package sense
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/cache"
+	"github.com/creamsensation/quirk"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type TfaContext interface {
+	Enroll(code ...string) (TfaEnrollment, error)
+	Confirm(otp string) error
+	Disable(code string) error
+	Login(user auth.User) error
+	Pending() bool
+	Verify(code string, remember bool) error
+}
+
+type TfaEnrollment struct {
+	Secret        string   `json:"secret"`
+	Url           string   `json:"url"`
+	Qr            string   `json:"qr"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type tfa struct {
+	ctx *handlerContext
+}
+
+type tfaDevice struct {
+	UserId     int   `json:"u"`
+	Expiration int64 `json:"e"`
+}
+
+const (
+	TfaDeviceCookieKey = "X-Tfa-Device"
+)
+
+const (
+	TfaCodeUserId = "user_id"
+	TfaCodeHash   = "hash"
+)
+
+const (
+	tfaAttemptsPrefix    = "tfa-attempts-"
+	tfaUsedPrefix        = "tfa-used-"
+	tfaUsedDuration      = 90 * time.Second
+	tfaPendingDuration   = 5 * time.Minute
+	tfaPendingBytes      = 32
+	tfaMaxAttempts       = 5
+	tfaAttemptsWindow    = 15 * time.Minute
+	tfaRememberDuration  = 30 * 24 * time.Hour
+	tfaQrSize            = 200
+	tfaRecoveryCodes     = 10
+	tfaMaxRecoveryCodes  = 15
+	tfaRecoveryCodeBytes = 5
+	tfaCodesTable        = "tfa_codes"
+)
+
+var (
+	tfaRecoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+	pgTfaCodeFields     = []quirk.Field{
+		{Name: quirk.Id, Props: "serial primary key"},
+		{Name: TfaCodeUserId, Props: "int not null references users (id) on delete cascade"},
+		{Name: TfaCodeHash, Props: "varchar(64) not null unique"},
+	}
+)
+
+func (t *tfa) Enroll(code ...string) (TfaEnrollment, error) {
+	var result TfaEnrollment
+	secret := t.ctx.config.Security.Tfa.Secret
+	if len(secret) == 0 {
+		return result, ErrorInvalidTfaSecret
+	}
+	user, err := t.getUser()
+	if err != nil {
+		return result, err
+	}
+	if user.Tfa {
+		var c string
+		if len(code) > 0 {
+			c = code[0]
+		}
+		if err := t.throttle(user, func() (bool, error) { return t.verifyCode(user, c) }); err != nil {
+			return result, err
+		}
+	}
+	key, err := totp.Generate(
+		totp.GenerateOpts{
+			Issuer:      t.getIssuer(),
+			AccountName: user.Email,
+		},
+	)
+	if err != nil {
+		return result, err
+	}
+	qr, err := createTfaQr(key)
+	if err != nil {
+		return result, err
+	}
+	codes := createTfaRecoveryCodes(t.ctx.config.Security.Tfa)
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = createTfaRecoveryHash(secret, user.Id, code)
+	}
+	err = t.ctx.Auth().CustomUser(user.Id, user.Email).Update(
+		auth.User{
+			Tfa:       false,
+			TfaSecret: sql.Null[string]{V: key.Secret(), Valid: true},
+			TfaCodes:  sql.Null[string]{},
+			TfaUrl:    sql.Null[string]{V: key.String(), Valid: true},
+		},
+		auth.UserTfa, auth.UserTfaSecret, auth.UserTfaCodes, auth.UserTfaUrl,
+	)
+	if err != nil {
+		return result, err
+	}
+	if err := t.replaceRecoveryCodes(user.Id, hashes); err != nil {
+		return result, err
+	}
+	return TfaEnrollment{
+		Secret:        key.Secret(),
+		Url:           key.String(),
+		Qr:            qr,
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (t *tfa) Confirm(otp string) error {
+	user, err := t.getUser()
+	if err != nil {
+		return err
+	}
+	if len(user.TfaSecret.V) == 0 {
+		return ErrorTfaNotEnrolled
+	}
+	if err := t.throttle(user, func() (bool, error) { return t.validateOtp(user, otp), nil }); err != nil {
+		return err
+	}
+	return t.ctx.Auth().CustomUser(user.Id, user.Email).Update(auth.User{Tfa: true}, auth.UserTfa)
+}
+
+func (t *tfa) Disable(code string) error {
+	user, err := t.getUser()
+	if err != nil {
+		return err
+	}
+	if len(user.TfaSecret.V) == 0 {
+		return ErrorTfaNotEnrolled
+	}
+	if err := t.throttle(user, func() (bool, error) { return t.verifyCode(user, code) }); err != nil {
+		return err
+	}
+	if err := t.ctx.Auth().Tfa().Disable(user.Id); err != nil {
+		return err
+	}
+	if err := t.deleteRecoveryCodes(user.Id); err != nil {
+		return err
+	}
+	t.ctx.cookie.Destroy(TfaDeviceCookieKey)
+	return nil
+}
+
+func (t *tfa) Login(user auth.User) error {
+	t.ctx.principal = nil
+	if !user.Tfa || t.isDeviceRemembered(user) {
+		_, err := t.ctx.Auth().Session().New(user)
+		return err
+	}
+	token := createTfaPendingToken()
+	if err := t.ctx.Cache().Set(auth.TfaCacheKey+":"+token, auth.User{Id: user.Id}, tfaPendingDuration); err != nil {
+		return err
+	}
+	t.ctx.cookie.Set(auth.TfaCookieKey, token, tfaPendingDuration)
+	return nil
+}
+
+func (t *tfa) Pending() bool {
+	return isTfaPending(t.ctx.Cache(), t.ctx.cookie.Get(auth.TfaCookieKey))
+}
+
+func (t *tfa) Verify(code string, remember bool) error {
+	client := t.ctx.Cache()
+	token := t.ctx.cookie.Get(auth.TfaCookieKey)
+	if !isTfaPending(client, token) {
+		return ErrorForbidden
+	}
+	userId, err := t.ctx.Auth().Tfa().GetPendingUserId()
+	if err != nil {
+		return err
+	}
+	if userId == 0 {
+		return ErrorForbidden
+	}
+	user, err := t.ctx.Auth().CustomUser(userId, "").Get(userId)
+	if err != nil {
+		return err
+	}
+	if len(user.TfaSecret.V) == 0 {
+		return ErrorTfaNotEnrolled
+	}
+	if err := t.throttle(user, func() (bool, error) { return t.verifyCode(user, code) }); err != nil {
+		return err
+	}
+	if err := client.Destroy(auth.TfaCacheKey + ":" + token); err != nil {
+		return err
+	}
+	t.ctx.cookie.Destroy(auth.TfaCookieKey)
+	if _, err := t.ctx.Auth().Session().New(user); err != nil {
+		return err
+	}
+	t.ctx.principal = nil
+	if remember {
+		t.rememberDevice(user)
+	}
+	if cfg := getLoginConfig(t.ctx.config.Security.Login); cfg.Enabled {
+		return t.ctx.login.complete(cfg, user.Email)
+	}
+	return nil
+}
+
+func (t *tfa) resume(result auth.In) (auth.In, error) {
+	client := t.ctx.Cache()
+	key := auth.TfaCacheKey + ":" + result.Token
+	var pending auth.User
+	if err := client.Get(key, &pending); err != nil || pending.Id == 0 {
+		return result, err
+	}
+	user, err := t.ctx.Auth().CustomUser(pending.Id, "").Get(pending.Id)
+	if err != nil || !t.isDeviceRemembered(user) {
+		return result, err
+	}
+	if err := client.Destroy(key); err != nil {
+		return result, err
+	}
+	t.ctx.cookie.Destroy(auth.TfaCookieKey)
+	token, err := t.ctx.Auth().Session().New(user)
+	if err != nil {
+		return auth.In{}, err
+	}
+	t.ctx.principal = nil
+	return auth.In{Token: token, Ok: true}, nil
+}
+
+func (t *tfa) getUser() (auth.User, error) {
+	principal, err := t.ctx.Principal()
+	if err != nil {
+		return auth.User{}, err
+	}
+	if !principal.Authenticated() || principal.Kind != config.AuthenticationSession {
+		return auth.User{}, ErrorForbidden
+	}
+	return t.ctx.Auth().CustomUser(principal.Id, principal.Email).Get(principal.Id)
+}
+
+func (t *tfa) getIssuer() string {
+	if len(t.ctx.config.Security.Tfa.Issuer) > 0 {
+		return t.ctx.config.Security.Tfa.Issuer
+	}
+	if len(t.ctx.config.App.Name) > 0 {
+		return t.ctx.config.App.Name
+	}
+	return t.ctx.request.getForwarded().host
+}
+
+func (t *tfa) throttle(user auth.User, verify func() (bool, error)) error {
+	cache := t.ctx.atomic
+	key := createTfaAttemptsKey(user.Id)
+	attempts, err := incrementAtomic(cache, key, tfaAttemptsWindow)
+	if err != nil {
+		return err
+	}
+	if attempts > tfaMaxAttempts {
+		return ErrorTooManyRequests
+	}
+	ok, err := verify()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return createTfaError(t.ctx, ErrorInvalidTfaCode, t.ctx.config.Security.Tfa.Messages.InvalidCode)
+	}
+	return deleteAtomic(cache, key)
+}
+
+func (t *tfa) verifyCode(user auth.User, code string) (bool, error) {
+	if t.validateOtp(user, code) {
+		return true, nil
+	}
+	secret := t.ctx.config.Security.Tfa.Secret
+	if len(secret) == 0 {
+		return false, nil
+	}
+	var id int
+	err := t.ctx.Db().
+		Q(fmt.Sprintf(`DELETE FROM %s`, tfaCodesTable)).
+		Q(
+			`WHERE user_id = @user_id AND hash = @hash`,
+			quirk.Map{TfaCodeUserId: user.Id, TfaCodeHash: createTfaRecoveryHash(secret, user.Id, code)},
+		).
+		Q(`RETURNING id`).
+		Exec(&id)
+	return id > 0, err
+}
+
+func (t *tfa) replaceRecoveryCodes(userId int, hashes []string) error {
+	if err := t.deleteRecoveryCodes(userId); err != nil {
+		return err
+	}
+	q := t.ctx.Db().Q(fmt.Sprintf(`INSERT INTO %s (%s, %s) VALUES`, tfaCodesTable, TfaCodeUserId, TfaCodeHash))
+	for i, hash := range hashes {
+		value := `(@user_id, @hash)`
+		if i < len(hashes)-1 {
+			value += ","
+		}
+		q.Q(value, quirk.Map{TfaCodeUserId: userId, TfaCodeHash: hash})
+	}
+	return q.Exec()
+}
+
+func (t *tfa) deleteRecoveryCodes(userId int) error {
+	return t.ctx.Db().
+		Q(fmt.Sprintf(`DELETE FROM %s`, tfaCodesTable)).
+		Q(`WHERE user_id = @user_id`, quirk.Map{TfaCodeUserId: userId}).
+		Exec()
+}
+
+func (t *tfa) validateOtp(user auth.User, code string) bool {
+	code = strings.TrimSpace(code)
+	if !totp.Validate(code, user.TfaSecret.V) {
+		return false
+	}
+	used, err := incrementAtomic(t.ctx.atomic, createTfaUsedKey(user.Id, code), tfaUsedDuration)
+	return err == nil && used == 1
+}
+
+func (t *tfa) rememberDevice(user auth.User) {
+	secret := t.ctx.config.Security.Tfa.Secret
+	if len(secret) == 0 {
+		return
+	}
+	duration := t.ctx.config.Security.Tfa.Remember
+	if duration == 0 {
+		duration = tfaRememberDuration
+	}
+	payloadBytes, err := json.Marshal(tfaDevice{UserId: user.Id, Expiration: time.Now().Add(duration).Unix()})
+	if err != nil {
+		return
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+	t.ctx.cookie.Set(TfaDeviceCookieKey, payload+"."+createTfaDeviceSignature(secret, payload, user), duration)
+}
+
+func (t *tfa) isDeviceRemembered(user auth.User) bool {
+	secret := t.ctx.config.Security.Tfa.Secret
+	payload, signature, ok := strings.Cut(t.ctx.cookie.Get(TfaDeviceCookieKey), ".")
+	if len(secret) == 0 || !ok {
+		return false
+	}
+	if !hmac.Equal([]byte(signature), []byte(createTfaDeviceSignature(secret, payload, user))) {
+		return false
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	var device tfaDevice
+	if err := json.Unmarshal(payloadBytes, &device); err != nil {
+		return false
+	}
+	return device.UserId == user.Id && time.Now().Unix() < device.Expiration
+}
+
+func CreateTfaCodesTable(q *quirk.Quirk) error {
+	fields := make([]quirk.Field, 0)
+	switch q.DB.DriverName() {
+	case quirk.Postgres:
+		fields = append(fields, pgTfaCodeFields...)
+	default:
+		return ErrorInvalidDriver
+	}
+	q.Q(
+		fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (%s)`,
+			tfaCodesTable,
+			quirk.CreateTableStructure(fields),
+		),
+	)
+	return q.Exec()
+}
+
+func DropTfaCodesTable(q *quirk.Quirk) error {
+	return q.Q(fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, tfaCodesTable)).Exec()
+}
+
+func isTfaPending(client cache.Client, token string) bool {
+	return len(token) > 0 && client.Exists(auth.TfaCacheKey+":"+token)
+}
+
+func createTfaPendingToken() string {
+	bytes := make([]byte, tfaPendingBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func createTfaAttemptsKey(userId int) string {
+	return tfaAttemptsPrefix + strconv.Itoa(userId)
+}
+
+func createTfaUsedKey(userId int, code string) string {
+	hash := sha256.Sum256([]byte(strconv.Itoa(userId) + " " + code))
+	return tfaUsedPrefix + hex.EncodeToString(hash[:])
+}
+
+func createTfaDeviceSignature(secret, payload string, user auth.User) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload + "." + user.TfaSecret.V))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func createTfaQr(key *otp.Key) (string, error) {
+	img, err := key.Image(tfaQrSize, tfaQrSize)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func createTfaRecoveryCodes(cfg config.Tfa) []string {
+	count := cfg.RecoveryCodes
+	if count <= 0 {
+		count = tfaRecoveryCodes
+	}
+	count = min(count, tfaMaxRecoveryCodes)
+	result := make([]string, count)
+	for i := range result {
+		codeBytes := make([]byte, tfaRecoveryCodeBytes)
+		if _, err := rand.Read(codeBytes); err != nil {
+			panic(err)
+		}
+		code := strings.ToLower(tfaRecoveryEncoding.EncodeToString(codeBytes))
+		result[i] = code[:len(code)/2] + "-" + code[len(code)/2:]
+	}
+	return result
+}
+
+func createTfaRecoveryHash(secret string, userId int, code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.Itoa(userId) + " " + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func createTfaError(c Context, err error, message string) error {
+	if len(message) == 0 {
+		return err
+	}
+	return localizedError{err: err, message: c.Translate(message)}
+}
+
+func getSessionDuration(config auth.Config) time.Duration {
+	if config.Duration == 0 {
+		return auth.DefaultDuration
+	}
+	return config.Duration
+}
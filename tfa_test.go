@@ -0,0 +1,198 @@
+package sense
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/quirk"
+	"github.com/pquerna/otp/totp"
+
+	"github.com/creamsensation/sense/internal/constant/contentType"
+	"github.com/creamsensation/sense/internal/constant/header"
+	"github.com/creamsensation/sense/internal/constant/model"
+)
+
+type testTfaClient struct {
+	app     Sense
+	cookies map[string]*http.Cookie
+}
+
+const (
+	testTfaEmail    = "tfa@example.com"
+	testTfaPassword = "password"
+)
+
+func TestTfaEnrollAndVerify(t *testing.T) {
+	db := createTestTfaDatabase(t)
+	app := createTestTfaApp(db)
+	client := createTestTfaClient(app)
+	if res := client.send(t, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}); res != "session" {
+		t.Fatalf("expected session, got %s", res)
+	}
+	var enrollment TfaEnrollment
+	if err := json.Unmarshal([]byte(client.send(t, "/tfa/enroll", nil)), &enrollment); err != nil {
+		t.Fatal(err)
+	}
+	if len(enrollment.RecoveryCodes) != tfaRecoveryCodes {
+		t.Fatalf("expected %d recovery codes, got %d", tfaRecoveryCodes, len(enrollment.RecoveryCodes))
+	}
+	otp, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := client.send(t, "/tfa/confirm", url.Values{"code": {otp}}); res != "ok" {
+		t.Fatalf("expected confirmation, got %s", res)
+	}
+
+	pending := createTestTfaClient(app)
+	if res := pending.send(t, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}); res != "tfa" {
+		t.Fatalf("expected pending tfa, got %s", res)
+	}
+	if res := pending.send(t, "/tfa/verify", url.Values{"code": {otp}}); res == "ok" {
+		t.Fatal("expected used otp to be rejected")
+	}
+	if res := pending.send(t, "/tfa/verify", url.Values{"code": {enrollment.RecoveryCodes[0]}, "remember": {"true"}}); res != "ok" {
+		t.Fatalf("expected recovery code to verify, got %s", res)
+	}
+
+	reused := createTestTfaClient(app)
+	reused.send(t, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}})
+	if res := reused.send(t, "/tfa/verify", url.Values{"code": {enrollment.RecoveryCodes[0]}}); res == "ok" {
+		t.Fatal("expected used recovery code to be rejected")
+	}
+	if res := reused.send(t, "/tfa/verify", url.Values{"code": {enrollment.RecoveryCodes[1]}}); res != "ok" {
+		t.Fatalf("expected unused recovery code to verify, got %s", res)
+	}
+
+	remembered := createTestTfaClient(app)
+	remembered.cookies[TfaDeviceCookieKey] = pending.cookies[TfaDeviceCookieKey]
+	if res := remembered.send(t, "/login", url.Values{"email": {testTfaEmail}, "password": {testTfaPassword}}); res != "session" {
+		t.Fatalf("expected remembered device to skip tfa, got %s", res)
+	}
+}
+
+func createTestTfaDatabase(t *testing.T) *quirk.DB {
+	db, err := quirk.Connect(
+		quirk.WithPostgres(),
+		quirk.WithHost("localhost"),
+		quirk.WithPort(5432),
+		quirk.WithDbname("test"),
+		quirk.WithUser("cream"),
+		quirk.WithPassword("cream"),
+		quirk.WithSslDisable(),
+	)
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Skip(err)
+	}
+	if err := DropTfaCodesTable(quirk.New(db)); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.DropTable(quirk.New(db)); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CreateTable(quirk.New(db)); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateTfaCodesTable(quirk.New(db)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(
+		func() {
+			_ = DropTfaCodesTable(quirk.New(db))
+			_ = auth.DropTable(quirk.New(db))
+		},
+	)
+	if _, err := auth.CreateUserManager(db, nil, 0, "").Create(auth.User{Active: true, Email: testTfaEmail, Password: testTfaPassword}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createTestTfaApp(db *quirk.DB) Sense {
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Database = map[string]*quirk.DB{Main: db}
+	cfg.Security.Tfa.Secret = "tfa-secret"
+	app := New(cfg)
+	app.Post(
+		"/login", func(c Context) error {
+			result, err := c.Login().In(c.Request().Raw().FormValue("email"), c.Request().Raw().FormValue("password"))
+			if err != nil {
+				return c.Send().Text(err.Error())
+			}
+			if result.Tfa {
+				return c.Send().Text("tfa")
+			}
+			return c.Send().Text("session")
+		},
+	)
+	app.Post(
+		"/tfa/enroll", func(c Context) error {
+			enrollment, err := c.Tfa().Enroll()
+			if err != nil {
+				return c.Send().Text(err.Error())
+			}
+			return c.Send().Json(enrollment)
+		},
+	)
+	app.Post(
+		"/tfa/confirm", func(c Context) error {
+			return sendTestTfaResult(c, c.Tfa().Confirm(c.Request().Raw().FormValue("code")))
+		},
+	)
+	app.Post(
+		"/tfa/verify", func(c Context) error {
+			return sendTestTfaResult(c, c.Tfa().Verify(c.Request().Raw().FormValue("code"), c.Request().Raw().FormValue("remember") == "true"))
+		},
+	)
+	return app
+}
+
+func sendTestTfaResult(c Context, err error) error {
+	if err != nil {
+		return c.Send().Text(err.Error())
+	}
+	return c.Send().Text("ok")
+}
+
+func createTestTfaClient(app Sense) *testTfaClient {
+	return &testTfaClient{app: app, cookies: make(map[string]*http.Cookie)}
+}
+
+func (c *testTfaClient) send(t *testing.T, path string, values url.Values) string {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	req.Header.Set(header.ContentType, contentType.Form)
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	c.app.(*sense).handler.ServeHTTP(res, req)
+	for _, cookie := range res.Result().Cookies() {
+		if cookie.MaxAge < 0 || len(cookie.Value) == 0 {
+			delete(c.cookies, cookie.Name)
+			continue
+		}
+		c.cookies[cookie.Name] = cookie
+	}
+	var body model.Json
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected response: %d %s", res.Code, res.Body.String())
+	}
+	if result, ok := body.Result.(string); ok {
+		return result
+	}
+	bytes, err := json.Marshal(body.Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes)
+}
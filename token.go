@@ -0,0 +1,180 @@
+package sense
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/creamsensation/quirk"
+)
+
+type TokenManager interface {
+	Create(userId int, name string, scopes []string, expiration time.Duration) (string, error)
+	Get(token string) (Token, error)
+	List(userId int) ([]Token, error)
+	Revoke(id int) error
+}
+
+type Token struct {
+	Id         int                 `json:"id"`
+	UserId     int                 `json:"userId"`
+	Name       string              `json:"name"`
+	Scopes     []string            `json:"scopes"`
+	ExpiresAt  sql.Null[time.Time] `json:"expiresAt"`
+	LastUsedAt sql.Null[time.Time] `json:"lastUsedAt"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
+
+type tokenManager struct {
+	db *quirk.DB
+}
+
+const (
+	TokenPrefix = "sns_"
+)
+
+const (
+	TokenUserId     = "user_id"
+	TokenName       = "name"
+	TokenHash       = "hash"
+	TokenScopes     = "scopes"
+	TokenExpiresAt  = "expires_at"
+	TokenLastUsedAt = "last_used_at"
+)
+
+const (
+	tokensTable  = "tokens"
+	tokenBytes   = 32
+	tokenColumns = "id, user_id, name, scopes, expires_at, last_used_at, created_at"
+
+	tokenLastUsedInterval = time.Minute
+)
+
+var (
+	pgTokenFields = []quirk.Field{
+		{Name: quirk.Id, Props: "serial primary key"},
+		{Name: TokenUserId, Props: "int not null references users (id) on delete cascade"},
+		{Name: TokenName, Props: "varchar(255) not null"},
+		{Name: TokenHash, Props: "varchar(64) not null unique"},
+		{Name: TokenScopes, Props: "varchar[]"},
+		{Name: TokenExpiresAt, Props: "timestamp"},
+		{Name: TokenLastUsedAt, Props: "timestamp"},
+		{Name: quirk.CreatedAt, Props: "timestamp not null default current_timestamp"},
+	}
+)
+
+func createTokenManager(db *quirk.DB) TokenManager {
+	return &tokenManager{db: db}
+}
+
+func (m *tokenManager) Create(userId int, name string, scopes []string, expiration time.Duration) (string, error) {
+	bytes := make([]byte, tokenBytes)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	token := TokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+	var expiresAt sql.Null[time.Time]
+	if expiration > 0 {
+		expiresAt = sql.Null[time.Time]{V: time.Now().Add(expiration), Valid: true}
+	}
+	if scopes == nil {
+		scopes = make([]string, 0)
+	}
+	var id int
+	err := quirk.New(m.db).Q(fmt.Sprintf(`INSERT INTO %s`, tokensTable)).
+		Q(
+			fmt.Sprintf(`(%s, %s, %s, %s, %s)`, TokenUserId, TokenName, TokenHash, TokenScopes, TokenExpiresAt),
+		).
+		Q(
+			`VALUES (@user_id, @name, @hash, @scopes, @expires_at)`,
+			quirk.Map{
+				TokenUserId:    userId,
+				TokenName:      name,
+				TokenHash:      createTokenHash(token),
+				TokenScopes:    scopes,
+				TokenExpiresAt: expiresAt,
+			},
+		).
+		Q(`RETURNING id`).
+		Exec(&id)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (m *tokenManager) Get(token string) (Token, error) {
+	var r Token
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return r, nil
+	}
+	err := quirk.New(m.db).
+		Q(fmt.Sprintf(`SELECT %s FROM %s`, tokenColumns, tokensTable)).
+		Q(`WHERE hash = @hash`, quirk.Map{TokenHash: createTokenHash(token)}).
+		Q(`AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`).
+		Exec(&r)
+	if err != nil || r.Id == 0 {
+		return r, err
+	}
+	now := time.Now()
+	if r.LastUsedAt.Valid && now.Sub(r.LastUsedAt.V) < tokenLastUsedInterval {
+		return r, nil
+	}
+	err = quirk.New(m.db).
+		Q(fmt.Sprintf(`UPDATE %s SET %s = @%s`, tokensTable, TokenLastUsedAt, TokenLastUsedAt), quirk.Map{TokenLastUsedAt: now}).
+		Q(`WHERE id = @id`, quirk.Map{quirk.Id: r.Id}).
+		Q(
+			fmt.Sprintf(`AND (%s IS NULL OR %s < @threshold)`, TokenLastUsedAt, TokenLastUsedAt),
+			quirk.Map{"threshold": now.Add(-tokenLastUsedInterval)},
+		).
+		Exec()
+	r.LastUsedAt = sql.Null[time.Time]{V: now, Valid: true}
+	return r, err
+}
+
+func (m *tokenManager) List(userId int) ([]Token, error) {
+	r := make([]Token, 0)
+	err := quirk.New(m.db).
+		Q(fmt.Sprintf(`SELECT %s FROM %s`, tokenColumns, tokensTable)).
+		Q(`WHERE user_id = @user_id`, quirk.Map{TokenUserId: userId}).
+		Q(`ORDER BY id`).
+		Exec(&r)
+	return r, err
+}
+
+func (m *tokenManager) Revoke(id int) error {
+	return quirk.New(m.db).
+		Q(fmt.Sprintf(`DELETE FROM %s`, tokensTable)).
+		Q(`WHERE id = @id`, quirk.Map{quirk.Id: id}).
+		Exec()
+}
+
+func CreateTokensTable(q *quirk.Quirk) error {
+	fields := make([]quirk.Field, 0)
+	switch q.DB.DriverName() {
+	case quirk.Postgres:
+		fields = append(fields, pgTokenFields...)
+	}
+	q.Q(
+		fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s (%s)`,
+			tokensTable,
+			quirk.CreateTableStructure(fields),
+		),
+	)
+	return q.Exec()
+}
+
+func DropTokensTable(q *quirk.Quirk) error {
+	return q.Q(fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, tokensTable)).Exec()
+}
+
+func createTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,75 @@
+package sense
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	
+	"github.com/creamsensation/sense/config"
+	"github.com/creamsensation/sense/internal/constant/header"
+)
+
+func createRouteUrl(route *Route, trailingSlash string, params map[string]any, query ...url.Values) string {
+	u := replaceRouteParams(route.Path, route, params)
+	if trailingSlash == config.TrailingSlashAppend && !strings.HasSuffix(route.Path, "...}") && !strings.HasSuffix(u, "/") {
+		u += "/"
+	}
+	if len(u) == 0 {
+		u = "/"
+	}
+	if len(query) > 0 && len(query[0]) > 0 {
+		u += "?" + query[0].Encode()
+	}
+	return u
+}
+
+func createRouteHost(route *Route, params map[string]any) string {
+	return replaceRouteParams(route.Host, route, params)
+}
+
+func replaceRouteParams(path string, route *Route, params map[string]any) string {
+	var result strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '{' {
+			result.WriteByte(path[i])
+			continue
+		}
+		end := strings.IndexByte(path[i:], '}') + i
+		name := path[i+1 : end]
+		i = end
+		if name == "$" {
+			continue
+		}
+		remainder := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+		param, ok := params[name]
+		if !ok {
+			panic(fmt.Errorf("%w: %s", ErrorUrlParamMissing, name))
+		}
+		value := fmt.Sprintf("%v", param)
+		for _, constraint := range route.Constraints {
+			if constraint.Name == name && !constraint.match(value) {
+				panic(fmt.Errorf("%w: %s", ErrorUrlParamInvalid, name))
+			}
+		}
+		if !remainder {
+			result.WriteString(url.PathEscape(value))
+			continue
+		}
+		segments := strings.Split(value, "/")
+		for j, segment := range segments {
+			segments[j] = url.PathEscape(segment)
+		}
+		result.WriteString(strings.Join(segments, "/"))
+	}
+	return result.String()
+}
+
+func getForwardedPrefix(req *http.Request, proxies []*net.IPNet) string {
+	if !isRequestFromTrustedProxy(req, proxies) {
+		return ""
+	}
+	return formatPath(req.Header.Get(header.ForwardedPrefix))
+}
@@ -1,12 +1,19 @@
 package sense
 
 import (
+	"encoding"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"reflect"
 	"regexp"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 	
 	"github.com/creamsensation/sense/config"
 	"github.com/creamsensation/sense/internal/constant/contentType"
@@ -14,10 +21,19 @@ import (
 	"github.com/creamsensation/sense/internal/constant/model"
 )
 
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	funcSuffixMatcher = regexp.MustCompile(`(\.func[0-9]+)+$`)
+)
+
 func isRequestMultipart(req *http.Request) bool {
 	return strings.Contains(req.Header.Get(header.ContentType), contentType.MultipartForm)
 }
 
+func isRequestForm(req *http.Request) bool {
+	return strings.Contains(req.Header.Get(header.ContentType), contentType.Form)
+}
+
 func getFileSuffixFromName(filename string) string {
 	parts := strings.Split(filename, ".")
 	if len(parts) < 2 {
@@ -46,39 +62,13 @@ func wrapResult(v any) ([]byte, error) {
 	return json.Marshal(model.Json{Result: v})
 }
 
-func assertStringToType[T Assert](v string) T {
+func parseStringsToType[T any](values []string) (T, error) {
 	result := *new(T)
-	switch any(result).(type) {
-	case string:
-		result = any(escapeString(v)).(T)
-	case bool:
-		result = any(v == "true").(T)
-	case int:
-		res, err := strconv.Atoi(v)
-		if err == nil {
-			result = any(res).(T)
-		}
-	case float32:
-		res, err := strconv.ParseFloat(v, 32)
-		if err == nil {
-			result = any(float32(res)).(T)
-		}
-	case float64:
-		res, err := strconv.ParseFloat(v, 64)
-		if err == nil {
-			result = any(res).(T)
-		}
-	}
-	return result
+	err := setValueToReflected(reflect.ValueOf(&result).Elem(), values...)
+	return result, err
 }
 
-func escapeString(value string) string {
-	replacer := strings.NewReplacer("<", "&lt;", ">", "&gt;", "'", "", "\"", "", "`", "")
-	value = replacer.Replace(value)
-	return value
-}
-
-func findFirewallsWithPath(path string, firewalls []config.Firewall) []config.Firewall {
+func findFirewallsWithRoute(path string, firewalls []config.Firewall) []config.Firewall {
 	result := make([]config.Firewall, 0)
 	for _, firewall := range firewalls {
 		if !firewall.Enabled {
@@ -97,18 +87,146 @@ func findFirewallsWithPath(path string, firewalls []config.Firewall) []config.Fi
 	return result
 }
 
-func setValueToReflected(kind reflect.Kind, field reflect.Value, value string) {
-	switch kind {
+func getFirewallsRoles(firewalls []config.Firewall) []string {
+	result := make([]string, 0)
+	for _, firewall := range firewalls {
+		for _, role := range firewall.Roles {
+			if !slices.Contains(result, role) {
+				result = append(result, role)
+			}
+		}
+	}
+	return result
+}
+
+func getFuncName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	return funcSuffixMatcher.ReplaceAllString(name, "")
+}
+
+func getFuncNames[T any](fns []T) []string {
+	result := make([]string, len(fns))
+	for i, fn := range fns {
+		result[i] = getFuncName(fn)
+	}
+	return result
+}
+
+func setValueToReflected(field reflect.Value, values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValueToReflected(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	value := values[0]
+	if field.Type() == timeType {
+		t, err := parseTime(value)
+		if err != nil {
+			return errors.Join(ErrorInvalidValue, err)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if field.CanAddr() {
+		if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := unmarshaler.UnmarshalText([]byte(value)); err != nil {
+				return errors.Join(ErrorInvalidValue, err)
+			}
+			return nil
+		}
+	}
+	switch field.Kind() {
 	case reflect.String:
-		field.Set(reflect.ValueOf(value))
+		field.SetString(value)
 	case reflect.Bool:
-		field.Set(reflect.ValueOf(value == "true"))
-	case reflect.Int:
-		field.Set(reflect.ValueOf(assertStringToType[int](value)))
-	case reflect.Float32:
-		field.Set(reflect.ValueOf(assertStringToType[float64](value)))
-	case reflect.Float64:
-		field.Set(reflect.ValueOf(assertStringToType[float64](value)))
+		res, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Join(ErrorInvalidValue, err)
+		}
+		field.SetBool(res)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		res, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.Join(ErrorInvalidValue, err)
+		}
+		field.SetInt(res)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		res, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.Join(ErrorInvalidValue, err)
+		}
+		field.SetUint(res)
+	case reflect.Float32, reflect.Float64:
+		res, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.Join(ErrorInvalidValue, err)
+		}
+		field.SetFloat(res)
+	case reflect.Slice:
+		field.SetBytes([]byte(value))
 	default:
+		return ErrorUnsupportedType
+	}
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func parseIpNets(values []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+func containsIp(ip string, ipNets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,103 @@
+package sense
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type WsContext interface {
+	Get(key string) any
+	Set(key string, value any)
+	Delete(key string)
+	Json(value any) error
+	Text(value string) error
+	Close()
+}
+
+type WsOptions struct {
+	OnConnect    Handler
+	OnDisconnect Handler
+	OnError      func(c Context, err error)
+}
+
+type wsContext struct {
+	conn *wsConn
+}
+
+type wsResponseWriter struct {
+	header http.Header
+}
+
+func createWsContext(conn *wsConn) WsContext {
+	return &wsContext{conn: conn}
+}
+
+func createWsRequest(req *http.Request) *http.Request {
+	r := req.Clone(context.WithoutCancel(req.Context()))
+	r.Body = http.NoBody
+	return r
+}
+
+func createWsResponseWriter() http.ResponseWriter {
+	return &wsResponseWriter{header: make(http.Header)}
+}
+
+func (w *wsResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *wsResponseWriter) Write(bytes []byte) (int, error) {
+	return len(bytes), nil
+}
+
+func (w *wsResponseWriter) WriteHeader(int) {}
+
+func (w *wsContext) Get(key string) any {
+	if w.conn == nil {
+		return nil
+	}
+	value, _ := w.conn.state.Load(key)
+	return value
+}
+
+func (w *wsContext) Set(key string, value any) {
+	if w.conn == nil {
+		return
+	}
+	w.conn.state.Store(key, value)
+}
+
+func (w *wsContext) Delete(key string) {
+	if w.conn == nil {
+		return
+	}
+	w.conn.state.Delete(key)
+}
+
+func (w *wsContext) Json(value any) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return w.write(bytes)
+}
+
+func (w *wsContext) Text(value string) error {
+	return w.write([]byte(value))
+}
+
+func (w *wsContext) Close() {
+	if w.conn == nil {
+		return
+	}
+	w.conn.close()
+}
+
+func (w *wsContext) write(bytes []byte) error {
+	if w.conn == nil {
+		return ErrorInvalidWebsocket
+	}
+	w.conn.send(bytes)
+	return nil
+}
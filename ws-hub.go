@@ -0,0 +1,269 @@
+package sense
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsHub struct {
+	mu       *sync.RWMutex
+	upgrader *websocket.Upgrader
+	conns    map[*wsConn]bool
+	rooms    map[string]map[*wsConn]bool
+}
+
+type wsConn struct {
+	hub     *wsHub
+	conn    *websocket.Conn
+	id      int
+	session string
+	write   chan []byte
+	rooms   map[string]bool
+	state   *sync.Map
+	once    *sync.Once
+}
+
+type wsEvents struct {
+	connect    func(conn *wsConn)
+	message    func(conn *wsConn, message []byte)
+	disconnect func(conn *wsConn)
+}
+
+const (
+	wsReadLimit       = 512
+	wsWriteLimit      = 512
+	wsReadBufferSize  = 1024
+	wsWriteBufferSize = 1024
+	wsWriteDuration   = 10 * time.Second
+	wsPongDuration    = 60 * time.Second
+	wsPingPeriod      = (wsPongDuration * 9) / 10
+)
+
+var (
+	wsNewline = []byte{'\n'}
+	wsSpace   = []byte{' '}
+)
+
+func createWsHub() *wsHub {
+	return &wsHub{
+		mu: &sync.RWMutex{},
+		upgrader: &websocket.Upgrader{
+			ReadBufferSize:  wsReadBufferSize,
+			WriteBufferSize: wsWriteBufferSize,
+		},
+		conns: make(map[*wsConn]bool),
+		rooms: make(map[string]map[*wsConn]bool),
+	}
+}
+
+func (h *wsHub) serve(res http.ResponseWriter, req *http.Request, id int, session string, events wsEvents) error {
+	conn, err := h.upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		return err
+	}
+	c := &wsConn{
+		hub:     h,
+		conn:    conn,
+		id:      id,
+		session: session,
+		write:   make(chan []byte, wsWriteLimit),
+		rooms:   make(map[string]bool),
+		state:   &sync.Map{},
+		once:    &sync.Once{},
+	}
+	h.mu.Lock()
+	h.conns[c] = true
+	h.mu.Unlock()
+	go c.watchWrite()
+	if events.connect != nil {
+		events.connect(c)
+	}
+	go c.watchRead(events)
+	return nil
+}
+
+func (h *wsHub) find(ids []int, rooms []string) []*wsConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	result := make([]*wsConn, 0)
+	for c := range h.conns {
+		if len(ids) > 0 && !slices.Contains(ids, c.id) {
+			continue
+		}
+		if len(rooms) > 0 && !slices.ContainsFunc(
+			rooms, func(room string) bool {
+				return c.rooms[room]
+			},
+		) {
+			continue
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func (h *wsHub) join(c *wsConn, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.conns[c] {
+		return
+	}
+	for _, room := range rooms {
+		if _, ok := h.rooms[room]; !ok {
+			h.rooms[room] = make(map[*wsConn]bool)
+		}
+		h.rooms[room][c] = true
+		c.rooms[room] = true
+	}
+}
+
+func (h *wsHub) leave(c *wsConn, rooms ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveRooms(c, rooms...)
+}
+
+func (h *wsHub) leaveRooms(c *wsConn, rooms ...string) {
+	for _, room := range rooms {
+		delete(h.rooms[room], c)
+		if len(h.rooms[room]) == 0 {
+			delete(h.rooms, room)
+		}
+		delete(c.rooms, room)
+	}
+}
+
+func (h *wsHub) members(room string) []int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	result := make([]int, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		if !slices.Contains(result, c.id) {
+			result = append(result, c.id)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (h *wsHub) roomNames() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	result := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		result = append(result, room)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (h *wsHub) closeSession(session string, message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.conns {
+		if len(session) == 0 || c.session != session {
+			continue
+		}
+		select {
+		case c.write <- message:
+		default:
+		}
+		h.remove(c)
+	}
+}
+
+func (h *wsHub) unregister(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.conns[c] {
+		return
+	}
+	h.remove(c)
+}
+
+func (h *wsHub) remove(c *wsConn) {
+	delete(h.conns, c)
+	for room := range c.rooms {
+		h.leaveRooms(c, room)
+	}
+	close(c.write)
+}
+
+func (c *wsConn) send(message []byte) {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if !c.hub.conns[c] {
+		return
+	}
+	select {
+	case c.write <- message:
+	default:
+		go c.close()
+	}
+}
+
+func (c *wsConn) watchRead(events wsEvents) {
+	defer func() {
+		c.close()
+		if events.disconnect != nil {
+			events.disconnect(c)
+		}
+	}()
+	c.conn.SetReadLimit(wsReadLimit)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongDuration))
+	c.conn.SetPongHandler(
+		func(string) error {
+			return c.conn.SetReadDeadline(time.Now().Add(wsPongDuration))
+		},
+	)
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if events.message == nil {
+			continue
+		}
+		events.message(c, bytes.TrimSpace(bytes.ReplaceAll(message, wsNewline, wsSpace)))
+	}
+}
+
+func (c *wsConn) watchWrite() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+	for {
+		select {
+		case message, ok := <-c.write:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteDuration))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, nil)
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteDuration))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) close() {
+	c.once.Do(
+		func() {
+			c.hub.unregister(c)
+			_ = c.conn.Close()
+		},
+	)
+}
@@ -4,30 +4,39 @@ import (
 	"encoding/json"
 	"slices"
 
-	"github.com/creamsensation/auth"
-	"github.com/creamsensation/socketer"
+	"github.com/creamsensation/sense/config"
 )
 
 type WsWriter interface {
 	Id(id ...int) WsWriter
 	Session() WsWriter
+	Room(room ...string) WsWriter
+	Join(room ...string) error
+	Leave(room ...string) error
+	Members(room string) []int
+	Rooms() []string
 	Json(value any) error
 	Text(value string) error
 }
 
 type wsWriter struct {
-	name string
-	auth auth.Manager
-	ids  []int
-	ws   map[string]socketer.Ws
+	name      string
+	principal func() (Principal, error)
+	conn      *wsConn
+	ids       []int
+	rooms     []string
+	targeted  bool
+	ws        map[string]*wsHub
 }
 
-func createWsWriter(ws map[string]socketer.Ws, name string, auth auth.Manager) WsWriter {
+func createWsWriter(ws map[string]*wsHub, name string, principal func() (Principal, error), conn *wsConn) WsWriter {
 	return &wsWriter{
-		name: name,
-		auth: auth,
-		ids:  make([]int, 0),
-		ws:   ws,
+		name:      name,
+		principal: principal,
+		conn:      conn,
+		ids:       make([]int, 0),
+		rooms:     make([]string, 0),
+		ws:        ws,
 	}
 }
 
@@ -36,56 +45,113 @@ func (s *wsWriter) Send() WsWriter {
 }
 
 func (s *wsWriter) Session() WsWriter {
-	session := s.auth.Session().MustGet()
-	if slices.Contains(s.ids, session.Id) {
+	session, err := s.principal()
+	if err != nil {
+		panic(err)
+	}
+	s.targeted = true
+	if session.Authenticated() && session.Kind == config.AuthenticationSession && session.Id > 0 && !slices.Contains(s.ids, session.Id) {
 		s.ids = append(s.ids, session.Id)
 	}
 	return s
 }
 
 func (s *wsWriter) Id(id ...int) WsWriter {
+	s.targeted = true
 	for _, item := range id {
-		if slices.Contains(s.ids, item) {
+		if item > 0 && !slices.Contains(s.ids, item) {
 			s.ids = append(s.ids, item)
 		}
 	}
 	return s
 }
 
-func (s *wsWriter) Json(value any) error {
-	bytes, err := json.Marshal(value)
+func (s *wsWriter) Room(room ...string) WsWriter {
+	for _, item := range room {
+		if !slices.Contains(s.rooms, item) {
+			s.rooms = append(s.rooms, item)
+		}
+	}
+	return s
+}
+
+func (s *wsWriter) Join(room ...string) error {
+	hub, conns, err := s.getMembershipTargets()
 	if err != nil {
 		return err
 	}
-	if len(s.ids) == 0 {
-		s.ws[s.name].Broadcast(bytes)
-		return nil
+	for _, c := range conns {
+		hub.join(c, room...)
 	}
-	clients, err := s.ws[s.name].Find(s.ids...)
+	return nil
+}
+
+func (s *wsWriter) Leave(room ...string) error {
+	hub, conns, err := s.getMembershipTargets()
 	if err != nil {
 		return err
 	}
-	for _, c := range clients {
-		if err := c.Write(bytes); err != nil {
-			return err
-		}
+	for _, c := range conns {
+		hub.leave(c, room...)
 	}
 	return nil
 }
 
-func (s *wsWriter) Text(value string) error {
-	if len(s.ids) == 0 {
-		s.ws[s.name].Broadcast([]byte(value))
-		return nil
+func (s *wsWriter) Members(room string) []int {
+	hub, ok := s.ws[s.name]
+	if !ok {
+		return make([]int, 0)
+	}
+	return hub.members(room)
+}
+
+func (s *wsWriter) Rooms() []string {
+	hub, ok := s.ws[s.name]
+	if !ok {
+		return make([]string, 0)
 	}
-	clients, err := s.ws[s.name].Find(s.ids...)
+	return hub.roomNames()
+}
+
+func (s *wsWriter) Json(value any) error {
+	bytes, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	for _, c := range clients {
-		if err := c.Write([]byte(value)); err != nil {
-			return err
-		}
+	return s.write(bytes)
+}
+
+func (s *wsWriter) Text(value string) error {
+	return s.write([]byte(value))
+}
+
+func (s *wsWriter) write(bytes []byte) error {
+	hub, ok := s.ws[s.name]
+	if !ok {
+		return ErrorInvalidWebsocket
+	}
+	if s.targeted && len(s.ids) == 0 {
+		return nil
+	}
+	for _, c := range hub.find(s.ids, s.rooms) {
+		c.send(bytes)
 	}
 	return nil
 }
+
+func (s *wsWriter) getMembershipTargets() (*wsHub, []*wsConn, error) {
+	hub, ok := s.ws[s.name]
+	if !ok {
+		return nil, nil, ErrorInvalidWebsocket
+	}
+	if s.targeted && len(s.ids) == 0 {
+		return hub, make([]*wsConn, 0), nil
+	}
+	if s.targeted {
+		return hub, hub.find(s.ids, nil), nil
+	}
+	if s.conn == nil || s.conn.hub != hub {
+		return nil, nil, ErrorInvalidWebsocket
+	}
+	return hub, []*wsConn{s.conn}, nil
+}
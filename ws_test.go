@@ -0,0 +1,231 @@
+package sense
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creamsensation/auth"
+	"github.com/creamsensation/cache"
+	"github.com/gorilla/websocket"
+
+	"github.com/creamsensation/sense/config"
+)
+
+type testWsApp struct {
+	server *httptest.Server
+	cache  cache.Client
+	hub    *wsHub
+	jwt    string
+}
+
+type testWsClient struct {
+	conn     *websocket.Conn
+	messages chan []string
+}
+
+const (
+	testWsName    = "test"
+	testWsDone    = "done"
+	testWsTimeout = 5 * time.Second
+)
+
+func TestWsTargetsOnlySessionConnections(t *testing.T) {
+	app := createTestWsApp(t)
+	first := app.dial(t, "/ws", app.session(t, 1))
+	second := app.dial(t, "/ws", app.session(t, 2))
+	other := app.dial(t, "/ws", app.session(t, 1))
+	anonymous := app.dial(t, "/ws", nil)
+	bearer := app.dial(t, "/ws/jwt", http.Header{"Authorization": {bearerPrefix + app.jwt}})
+	app.wait(t, 5)
+	for _, message := range []string{"id:1", "id:2", "session", "id", testWsDone} {
+		if err := first.conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[*testWsClient][]string{
+		first:     {"id:1", "session", testWsDone},
+		second:    {"id:2", testWsDone},
+		other:     {"id:1", "session", testWsDone},
+		anonymous: {testWsDone},
+		bearer:    {testWsDone},
+	}
+	for client, messages := range expected {
+		if received := client.receive(t); !slices.Equal(received, messages) {
+			t.Fatalf("expected %v, got %v", messages, received)
+		}
+	}
+}
+
+func TestWsClosesExpiredSessionConnection(t *testing.T) {
+	app := createTestWsApp(t)
+	header := app.session(t, 1)
+	client := app.dial(t, "/ws", header)
+	app.wait(t, 1)
+	cookie, err := (&http.Request{Header: header}).Cookie(auth.SessionCookieKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.cache.Destroy(auth.SessionCacheKey + ":" + cookie.Value); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.conn.WriteMessage(websocket.TextMessage, []byte("id:1")); err != nil {
+		t.Fatal(err)
+	}
+	if received := client.receive(t); len(received) > 0 {
+		t.Fatalf("expected expired session to be disconnected, got %v", received)
+	}
+	if conns := app.hub.find(nil, nil); len(conns) > 0 {
+		t.Fatalf("expected no websocket connections, got %d", len(conns))
+	}
+}
+
+func TestWsRejectsPlainRequest(t *testing.T) {
+	app := createTestWsApp(t)
+	res, err := app.server.Client().Get(app.server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func createTestWsApp(t *testing.T) *testWsApp {
+	provider := &testOidcProvider{kid: "k1"}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.key = key
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	jwksBytes, err := json.Marshal(
+		jwks{
+			Keys: []jwk{
+				{
+					Kty: "RSA",
+					Kid: provider.kid,
+					Alg: "RS256",
+					Use: "sig",
+					N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jwksPath, jwksBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{}
+	cfg.Router.Quiet = true
+	cfg.Security.Authentication.Jwt = config.Jwt{Jwks: jwksPath, IdClaim: "uid"}
+	cfg.Security.Firewalls = []config.Firewall{
+		{Enabled: true, Patterns: []string{"^/ws/jwt$"}, Authentication: []string{config.AuthenticationJwt}},
+	}
+	app := New(cfg)
+	handler := func(c Context) error {
+		message, err := c.Parse().Text()
+		if err != nil {
+			return err
+		}
+		ws := c.Send().Ws(testWsName)
+		switch {
+		case message == "session":
+			return ws.Session().Text(message)
+		case message == "id":
+			return ws.Id().Text(message)
+		case strings.HasPrefix(message, "id:"):
+			id, err := strconv.Atoi(strings.TrimPrefix(message, "id:"))
+			if err != nil {
+				return err
+			}
+			return ws.Id(id).Text(message)
+		}
+		return ws.Text(message)
+	}
+	app.Ws("/ws", testWsName, handler)
+	app.Ws("/ws/jwt", testWsName, handler)
+	server := httptest.NewServer(app.(*sense).handler)
+	t.Cleanup(server.Close)
+	return &testWsApp{
+		server: server,
+		cache:  createCache(context.Background(), cfg.Cache, app.(*sense).atomic),
+		hub:    app.(*sense).ws[testWsName],
+		jwt:    provider.sign(map[string]any{"sub": "1", "uid": 1, "exp": time.Now().Add(time.Minute).Unix()}),
+	}
+}
+
+func (a *testWsApp) session(t *testing.T, id int) http.Header {
+	token := createOidcRandom()
+	if err := a.cache.Set(auth.SessionCacheKey+":"+token, auth.Session{Id: id}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	return http.Header{"Cookie": {(&http.Cookie{Name: auth.SessionCookieKey, Value: token}).String()}}
+}
+
+func (a *testWsApp) dial(t *testing.T, path string, header http.Header) *testWsClient {
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(a.server.URL, "http")+path, header)
+	if err != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		t.Fatalf("dial %s: %d %v", path, status, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client := &testWsClient{conn: conn, messages: make(chan []string, 1)}
+	go client.read()
+	return client
+}
+
+func (a *testWsApp) wait(t *testing.T, count int) {
+	deadline := time.Now().Add(testWsTimeout)
+	for len(a.hub.find(nil, nil)) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d websocket connections", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (c *testWsClient) read() {
+	result := make([]string, 0)
+	defer func() { c.messages <- result }()
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(testWsTimeout))
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		result = append(result, string(message))
+		if string(message) == testWsDone {
+			return
+		}
+	}
+}
+
+func (c *testWsClient) receive(t *testing.T) []string {
+	select {
+	case messages := <-c.messages:
+		return messages
+	case <-time.After(2 * testWsTimeout):
+		t.Fatal("websocket read timed out")
+		return nil
+	}
+}